@@ -0,0 +1,47 @@
+// Command replay re-simulates a recorded game log against the engine and
+// checks the board states and result match the recording.
+//
+//	go run ./cmd/replay -cards cards replays/<room id>.jsonl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kenzokravin/tic-tac-toe/rooms"
+)
+
+var cardDir = flag.String("cards", "cards", "Directory containing the card catalogue the game was played with.")
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Println("usage: replay [-cards dir] <replay file>")
+		os.Exit(2)
+	}
+
+	if _, err := rooms.LoadCardsFromDir(*cardDir); err != nil { //Loading cards from the catalogue.
+		fmt.Println("Card catalogue error:", err)
+		os.Exit(1)
+	}
+
+	history, err := rooms.ReadReplayFile(flag.Arg(0))
+	if err != nil {
+		fmt.Println("Error reading replay:", err)
+		os.Exit(1)
+	}
+
+	room, err := rooms.ReplayHistory(history)
+	if err != nil {
+		fmt.Println("Replay failed:", err)
+		os.Exit(1)
+	}
+
+	if room.Result != nil {
+		fmt.Println("Replay matches. Result:", room.Result.Reason, "winner:", room.Result.WinnerFaction)
+	} else {
+		fmt.Println("Replay matches. Game did not finish.")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/kenzokravin/tic-tac-toe/rooms"
+)
+
+type deckRequest struct { //Body of deck create and validate requests. Either cards or code must be set.
+	Owner string         `json:"owner"`
+	Name  string         `json:"name"`
+	Cards rooms.DeckList `json:"cards,omitempty"`
+	Code  string         `json:"code,omitempty"`
+}
+
+type deckValidation struct { //Response of the validate endpoint.
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+	Code   string   `json:"code,omitempty"`
+}
+
+func registerDeckHandlers() { //Adds the deck building endpoints.
+	http.HandleFunc("GET /decks", listDecksHandler)
+	http.HandleFunc("POST /decks", createDeckHandler)
+	http.HandleFunc("POST /decks/validate", validateDeckHandler)
+	http.HandleFunc("DELETE /decks/{id}", deleteDeckHandler)
+	http.HandleFunc("GET /decks/rules", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, rooms.DefaultDeckRules)
+	})
+}
+
+func authorizeOwner(w http.ResponseWriter, r *http.Request, owner string) bool { //Checks the request's X-Rating-Token owns the name. An unclaimed name is claimed and its new token sent back in X-Rating-Token.
+
+	if owner == "" {
+		http.Error(w, "owner is required", http.StatusBadRequest)
+		return false
+	}
+
+	issued, err := rooms.ClaimRatedName(owner, r.Header.Get("X-Rating-Token"))
+	if errors.Is(err, rooms.ErrNameClaimed) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if issued != "" {
+		w.Header().Set("X-Rating-Token", issued)
+	}
+
+	return true
+}
+
+func listDecksHandler(w http.ResponseWriter, r *http.Request) { //Lists a player's saved decks (GET /decks?owner=NAME).
+
+	owner := r.URL.Query().Get("owner")
+	if !authorizeOwner(w, r, owner) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rooms.ListDecks(owner))
+}
+
+func createDeckHandler(w http.ResponseWriter, r *http.Request) { //Validates and saves a deck list.
+
+	req, list, err := readDeckRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeOwner(w, r, req.Owner) {
+		return
+	}
+
+	deck, err := rooms.SaveDeck(req.Owner, req.Name, list)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, deck)
+}
+
+func validateDeckHandler(w http.ResponseWriter, r *http.Request) { //Checks a deck list without saving it.
+
+	_, list, err := readDeckRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, cardSet := rooms.CurrentCatalogue()
+
+	res := deckValidation{Valid: true}
+	for _, e := range rooms.ValidateDeckList(list, cardSet, rooms.DefaultDeckRules) {
+		res.Valid = false
+		res.Errors = append(res.Errors, e.Error())
+	}
+
+	if res.Valid {
+		res.Code, _ = rooms.EncodeDeckCode(list)
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func deleteDeckHandler(w http.ResponseWriter, r *http.Request) { //Deletes a saved deck (DELETE /decks/{id}?owner=NAME).
+
+	owner := r.URL.Query().Get("owner")
+	if !authorizeOwner(w, r, owner) {
+		return
+	}
+
+	if err := rooms.DeleteDeck(owner, r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func readDeckRequest(r *http.Request) (*deckRequest, rooms.DeckList, error) { //Decodes a deck request body, decoding the deck code if no cards were sent.
+
+	var req deckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, nil, err
+	}
+
+	if len(req.Cards) > 0 {
+		return &req, req.Cards, nil
+	}
+
+	if req.Code == "" {
+		return nil, nil, errors.New("cards or code is required")
+	}
+
+	list, err := rooms.DecodeDeckCode(req.Code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &req, list, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) { //Writes v as a JSON response.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
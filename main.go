@@ -2,8 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -13,6 +17,16 @@ import (
 var roomController = rooms.CreateRoomController() //Creating room controller.
 var pConMap = make(map[*websocket.Conn]uuid.UUID) //Key is player id, value is connection.
 
+var cardDir = flag.String("cards", "cards", "Directory containing the card catalogue files.")
+var cardWatch = flag.Duration("cards-watch", 5*time.Second, "How often to check the card catalogue for changes (0 disables).")
+var deckFile = flag.String("decks", "decks.json", "File saved deck lists are stored in.")
+var replayDirFlag = flag.String("replays", "replays", "Directory finished game logs are saved to.")
+var ratingFile = flag.String("ratings", "ratings.json", "File player ratings and rating history are stored in.")
+var matchFreq = flag.Duration("match-freq", time.Second, "How often the ranked queue is matched as rating windows widen.")
+var spectatorDelay = flag.Duration("spectator-delay", 0, "How far behind the game spectators are kept (0 sends live).")
+var reconnectGrace = flag.Duration("reconnect-grace", 30*time.Second, "How long a disconnected player's seat is held during a game.")
+var adminToken = flag.String("admin-token", "", "Token required by admin endpoints (empty disables them).")
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
@@ -27,9 +41,24 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Println("Client connected")
 
+	if id := r.URL.Query().Get("spectate"); id != "" { //Watching a live room (i.e. /ws?spectate=ROOM_ID)
+		spectate(conn, id)
+		return
+	}
+
+	if token := r.URL.Query().Get("resume"); token != "" { //Reconnecting to a game in progress (i.e. /ws?resume=TOKEN)
+		player, err := rooms.ResumeSession(token, conn)
+		if err == nil {
+			pConMap[conn] = player.ID
+			readPlayerMessages(conn, player)
+			return
+		}
+		fmt.Println("Resume failed:", err) //Joining as a new player instead.
+	}
+
 	player := &rooms.Player{ //Creating new player variable with ID and default values. This is a pointer value.
 		ID:        uuid.New(),    //Player ID
-		Name:      "anon_player", //Init Player display name.
+		Name:      "anon_player", //Init Player display name, replaced by ?name= which ratings are kept under.
 		Faction:   "null",
 		Turn:      false,                 //Setting turn to false.
 		Hand:      []*rooms.Card{},       //Init player's hand.
@@ -37,22 +66,94 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 		SendQueue: make(chan string, 16), // Init send queue with buffer of 16 messages.
 	}
 
+	if name := r.URL.Query().Get("name"); name != "" {
+		player.Name = name
+		player.RatingToken = r.URL.Query().Get("rating_token") //Proves the player owns the name they are rated under.
+	}
+
 	pConMap[conn] = player.ID //Inserting into pConMap for retrieval when messaged.
 
 	player.StartWriter() //Start writer for player.
 
-	rooms.JoinRoom(&roomController, player) //Adding player to available room  with room controller.
+	rooms.RegisterSession(player) //Sending the player their resume token.
+
+	opts := rooms.RoomOptions{ //Room variant requested by the client (i.e. /ws?board=four&rules=double)
+		Board: rooms.BoardConfigFromName(r.URL.Query().Get("board")),
+		Turns: rooms.TurnRulesFromName(r.URL.Query().Get("rules")),
+		Clock: rooms.ClockFromName(r.URL.Query().Get("clock")),
+		Deck:  rooms.DeckRuleFromName(r.URL.Query().Get("deck")),
+	}
+
+	switch {
+	case r.URL.Query().Get("room") != "": //Joining a private room by its code (i.e. /ws?room=CODE)
+		if err := rooms.JoinRoomByCode(player, r.URL.Query().Get("room")); err != nil {
+			rooms.SendErrorToPlayer(player, err.Error())
+			rooms.JoinRoom(roomController, player, opts) //Falling back to matchmaking.
+		}
+	case r.URL.Query().Get("queue") == "ranked": //Queueing for a rated game (i.e. /ws?queue=ranked&name=NAME&rating_token=TOKEN)
+		if err := rooms.EnqueuePlayer(roomController, player, opts); err != nil {
+			rooms.SendErrorToPlayer(player, err.Error())
+			rooms.JoinRoom(roomController, player, opts) //Falling back to matchmaking.
+		}
+	default:
+		rooms.JoinRoom(roomController, player, opts) //Adding player to available room  with room controller.
+	}
 
 	fmt.Println("Rooms: ", &roomController.Rooms)
 
+	readPlayerMessages(conn, player)
+}
+
+func spectate(conn *websocket.Conn, roomID string) { //Adds the connection as a spectator of the room until it drops.
+
+	spectator := &rooms.Player{ //Spectators use a player for their connection, they never join the room's players.
+		ID:        uuid.New(),
+		Name:      "spectator",
+		Conn:      conn,
+		Connected: true,
+		SendQueue: make(chan string, 16),
+	}
+
+	spectator.StartWriter()
+
+	var room *rooms.Room
+	if id, err := uuid.Parse(roomID); err == nil {
+		room = roomController.FindRoomByID(id)
+	}
+
+	if room == nil {
+		rooms.SendErrorToPlayer(spectator, rooms.ErrNotSpectatable.Error())
+	} else if err := room.AddSpectator(spectator); err != nil {
+		rooms.SendErrorToPlayer(spectator, err.Error())
+		room = nil
+	}
+
+	for { //Spectators cannot act, anything they send is rejected.
+
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+
+		rooms.SendErrorToPlayer(spectator, rooms.ErrSpectator.Error())
+	}
+
+	if room != nil {
+		room.RemoveSpectator(spectator)
+	}
+
+	spectator.Close()
+
+	fmt.Println("Spectator disconnected")
+}
+
+func readPlayerMessages(conn *websocket.Conn, player *rooms.Player) { //Reads the client's messages until the connection drops.
+
 	for { //Reading messages from clients.
 
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
 
-			rooms.FindRoomByPlayer(player).RemovePlayerFromRoom(player) //Removing player from room.
-
-			player.Close() //Close player connection.
+			rooms.DisconnectPlayer(player, conn) //Holding the player's seat or removing them from the room.
 
 			fmt.Println("Client disconnected")
 
@@ -70,19 +171,96 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 			// handle JSON parse error
 		}
 
-		rooms.ManagePlayerMessage(player, &clientMsg)
+		rooms.ManagePlayerMessage(roomController, player, &clientMsg)
 
 		// Echo message back
 		//conn.WriteMessage(websocket.TextMessage, []byte(string(msg)))
 	}
 }
 
+func reloadCardsHandler(w http.ResponseWriter, r *http.Request) { //Admin endpoint that reloads the card catalogue from disk.
+
+	if *adminToken == "" || r.Header.Get("X-Admin-Token") != *adminToken { //Only admins can reload.
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	version, err := rooms.LoadCardsFromDir(*cardDir)
+	if err != nil { //Current catalogue is kept on error.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"version": version})
+}
+
+func replayHandler(w http.ResponseWriter, r *http.Request) { //Streams a finished game's log as JSON lines (GET /replays/{id}).
+
+	roomID, err := uuid.Parse(r.PathValue("id")) //Parsing the ID also stops path traversal.
+	if err != nil {
+		http.Error(w, "invalid replay id", http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(rooms.ReplayPath(roomID))
+	if err != nil {
+		http.Error(w, "replay not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	io.Copy(w, f)
+}
+
 func main() {
-	rooms.CreateCards()               //Creating cards.
+	flag.Parse()
+
+	if _, err := rooms.LoadCardsFromDir(*cardDir); err != nil { //Loading cards from the catalogue.
+		fmt.Println("Card catalogue error:", err)
+		return
+	}
+
+	if err := rooms.LoadDeckStore(*deckFile); err != nil { //Loading saved decks.
+		fmt.Println("Deck store error:", err)
+		return
+	}
+
+	if err := rooms.SetReplayDir(*replayDirFlag); err != nil { //Saving finished games for replay.
+		fmt.Println("Replay directory error:", err)
+		return
+	}
+
+	if err := rooms.LoadRatingStore(*ratingFile); err != nil { //Loading player ratings.
+		fmt.Println("Rating store error:", err)
+		return
+	}
+
+	rooms.ReconnectGrace = *reconnectGrace
+	rooms.SpectatorDelay = *spectatorDelay
+
+	if *cardWatch > 0 { //Reloading cards when the files change.
+		rooms.StartCatalogueWatcher(*cardDir, *cardWatch)
+	}
+
 	roomController.StartRoomCleaner() //Starting room cleaner.
 
+	rooms.StartMatchmaker(roomController, *matchFreq) //Starting the ranked queue.
+
 	http.Handle("/", http.FileServer(http.Dir(".")))
 	http.HandleFunc("/ws", wsHandler)
+	http.HandleFunc("/admin/cards/reload", reloadCardsHandler)
+	registerDeckHandlers()
+	registerRatingHandlers()
+	http.HandleFunc("GET /rooms/live", func(w http.ResponseWriter, r *http.Request) { //Lists rooms that can be spectated.
+		writeJSON(w, http.StatusOK, roomController.LiveRooms())
+	})
+	http.HandleFunc("GET /replays/{id}", replayHandler)
 
 	fmt.Println("Server running at http://localhost:8080")
 	http.ListenAndServe(":8080", nil)
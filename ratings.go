@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/kenzokravin/tic-tac-toe/rooms"
+)
+
+const defaultLeaderboardSize = 50 //Ratings returned by the leaderboard when no limit is sent.
+
+func registerRatingHandlers() { //Adds the rating endpoints.
+	http.HandleFunc("GET /ratings", leaderboardHandler)
+	http.HandleFunc("GET /ratings/{name}", ratingHandler)
+}
+
+func leaderboardHandler(w http.ResponseWriter, r *http.Request) { //Lists the highest rated players (GET /ratings?limit=N).
+
+	limit := defaultLeaderboardSize
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	writeJSON(w, http.StatusOK, rooms.TopRatings(limit))
+}
+
+func ratingHandler(w http.ResponseWriter, r *http.Request) { //Returns a player's rating and rating history (GET /ratings/{name}).
+	writeJSON(w, http.StatusOK, rooms.GetRating(r.PathValue("name")))
+}
@@ -1,12 +1,46 @@
 package rooms
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+
+	"github.com/google/uuid"
 )
 
 type Board struct {
-	Slots []*Slot
+	Width     int //The number of columns on the board.
+	Height    int //The number of rows on the board.
+	WinLength int //The number of marks in a row needed to win.
+	Slots     []*Slot
+
+	changes []EffectChange //Effect changes from triggers, sent with the next game state.
+	rng     *rand.Rand     //The room's random source, used by random shapes.
+}
+
+// BoardConfig describes the geometry of a board, used to create boards and sent to clients.
+type BoardConfig struct {
+	Width     int `json:"width"`      //The number of columns on the board.
+	Height    int `json:"height"`     //The number of rows on the board.
+	WinLength int `json:"win_length"` //The number of marks in a row needed to win.
+}
+
+var BoardPresets = map[string]BoardConfig{ //Named board variants rooms can be created with.
+	"classic":     {Width: 3, Height: 3, WinLength: 3}, //Standard tic-tac-toe.
+	"four":        {Width: 4, Height: 4, WinLength: 4}, //Four in a row.
+	"gomoku_lite": {Width: 5, Height: 5, WinLength: 4}, //Small gomoku.
+	"wide":        {Width: 4, Height: 3, WinLength: 3}, //Rectangular variant.
+}
+
+const defaultBoardPreset = "classic"
+
+func BoardConfigFromName(name string) BoardConfig { //Returns the preset with the given name, falling back to the default preset.
+
+	if cfg, ok := BoardPresets[name]; ok {
+		return cfg
+	}
+
+	return BoardPresets[defaultBoardPreset]
 }
 
 // Slot struct. A board is composed of 9 slots.
@@ -17,14 +51,14 @@ type Slot struct {
 	Effects []*MarkEffect //The effects currently on the slot.
 }
 
-func CreateBoard() Board { //Creating and returning the Board filled with slots.
+func CreateBoard(cfg BoardConfig) Board { //Creating and returning the Board filled with slots.
 
-	board := Board{Slots: []*Slot{}}
+	board := Board{Width: cfg.Width, Height: cfg.Height, WinLength: cfg.WinLength, Slots: []*Slot{}}
 
-	for i := 0; i < 3; i++ {
-		for z := 0; z < 3; z++ {
+	for i := 0; i < cfg.Height; i++ {
+		for z := 0; z < cfg.Width; z++ {
 
-			id := i*3 + z
+			id := i*cfg.Width + z
 
 			nSlot := Slot{ID: id, Row: i, Col: z}
 
@@ -39,91 +73,57 @@ func CreateBoard() Board { //Creating and returning the Board filled with slots.
 
 }
 
-func DrawStartCards(player *Player) { //Drawing start cards.
-
-	cardsMu.RLock()
-
-	for i := 0; i < 3; i++ { //Draw 3 cards.
-
-		player.Hand = append(player.Hand, DrawCard()) //Add cards to player's hand.
+func DrawStartCards(player *Player, cardSet []*Card, emptyRule string, rng *rand.Rand) { //Building the player's deck from the room's catalogue and drawing start cards.
 
+	list := player.DeckList
+	if len(list) == 0 { //Players without a deck list use the default deck.
+		list = DefaultDeckList(cardSet)
 	}
-	cardsMu.RUnlock()
 
-}
-
-func DrawCard() *Card { //Draws a card from the initialized cards using chance (math/rand). Must make sure we are dealing with card copies or not.
-
-	// cmpRarity := 0.0 //compound rarity used to check values.
-	// prevRarity := 0.0
-
-	chance := rand.Float64() //chance value that is the card drawn. returns a value between [0.0,1.0]
-
-	// total := 0.0
-	// for _, c := range cards { //Normalizing the rarity to ensure it equals 1.0
-	// 	total += c.Rarity
-	// }
-	// for i := range cards {
-	// 	cards[i].Rarity /= total
-	// }
-
-	var cumulative float64 //Using weighted rarity.
-	for i := 0; i < len(cards); i++ {
-		cumulative += cards[i].Rarity
-		if chance <= cumulative {
-			fmt.Println("Card Drawn.")
-
-			retCard := cards[i] //Could change graphic path here for mark effect if they are x or o.
-
-			return retCard
-		}
+	deck, err := BuildDeck(list, cardSet, emptyRule, rng)
+	if err != nil {
+		fmt.Println("ERROR: Could not build deck:", err)
+		deck, _ = BuildDeck(DefaultDeckList(cardSet), cardSet, emptyRule, rng)
 	}
 
-	devCard := Card{Type: "Playable", Name: "Mark",
-		Description: "This is a dev mark card.", Rarity: 1.0, GraphicPath: "src/card_test_mark.png", MarkerPath: "src/naught.svg"} //ONLY FOR DEVELOPMENT PURPOSES, Only create if no cards are available (Should never happen)
+	player.Deck = deck
+	player.Hand = []*Card{}
 
-	return &devCard //ONLY FOR DEVELOPMENT PURPOSES.
+	player.DrawCards(startHandSize) //Draw start hand.
 
 }
 
-func (sl *Slot) AddEffectToSlot(mEffect *MarkEffect, player *Player) { //Method that adds the effect to the slot.
+func (sl *Slot) AddEffectToSlot(mEffect *MarkEffect, player *Player, rng *rand.Rand) *MarkEffect { //Method that adds the effect to the slot. Returns the placed effect, nil if not added.
 
-	if !mEffect.IsStackable { //If effect cannot be stacked, do not add to stack.
+	if !mEffect.IsStackable && mEffect.DamageType != DamagePlace { //Only damage, nothing is left on the slot. Non-stackable placements were checked for an empty slot by ValidateCardPlay.
 		fmt.Println("Effect is not stackable.")
-		return
+		return nil
 	}
 
 	fmt.Println("Adding slot effect.")
 
-	mEffect.Owner = player.ID
+	placed := mEffect.NewInstance(player.ID, rng) //Each slot gets its own mark so health and ownership are tracked per mark.
 
-	sl.Effects = append(sl.Effects, mEffect)
+	sl.Effects = append(sl.Effects, placed)
 
+	return placed
 }
 
-func PlayCard(room *Room, player *Player, pMsg *PlayerMessage) { //Plays a card.
+func PlayCard(room *Room, player *Player, pMsg *PlayerMessage) error { //Plays a card. Returns why the play was rejected, or nil on success.
 
 	if !player.Turn { //Check if player's turn and break function if not.
-		fmt.Println("ERROR: Not Player's Turn.") //Send error to console. Will have to change to send the client an error message.
-
-		msg := GameMessage{ //Create game message to send to clients.
-			Type: "error", //Setting type to error
-		}
-
-		SendMessageToPlayer(player, ConvertMsgToJson(&msg)) //Add Message to send queue and convert to json compatible.
-
-		return
+		fmt.Println("ERROR: Not Player's Turn.")
+		return errors.New("not your turn")
 	}
 
 	isCardAvailable := false
 
-	//This throws an error as cards need to be created.
-	playedCard := cards[0] //Creating a pointer to the default card.
+	var playedCard *Card //Pointer to the card being played, set once found in hand.
 
 	fmt.Println("Card name from data.", pMsg.CardName)
 
-	for i := 0; i < len(player.Hand); i++ { //Checking if card is in player's hand.
-		if pMsg.CardName == player.Hand[i].Name {
+	for i := 0; i < len(player.Hand); i++ { //Checking if card is in player's hand. Matches the instance ID if sent, otherwise the name.
+		if (pMsg.CardID != uuid.Nil && pMsg.CardID == player.Hand[i].InstanceID) || (pMsg.CardID == uuid.Nil && pMsg.CardName == player.Hand[i].Name) {
 			isCardAvailable = true      //Set card availability to true.
 			playedCard = player.Hand[i] //Set the played card to the reference of the card.
 			fmt.Println("Found Available Card: ", playedCard)
@@ -133,114 +133,100 @@ func PlayCard(room *Room, player *Player, pMsg *PlayerMessage) { //Plays a card.
 
 	if !isCardAvailable { //If card not available, break function.
 		fmt.Println("ERROR: Cannot play Card that is not in hand.")
-		return
+		return errors.New("card is not in hand")
 	}
 
-	fmt.Println("Target Slot is: ", pMsg.TargetSlotID)
-
-	if pMsg.TargetSlotID >= 9 || pMsg.TargetSlotID < 0 { //If slot is out of bounds, throw error.
-		fmt.Println("ERROR: Invalid Target Slot. ID out of bounds.")
-		return
+	if err := room.CheckPlaysLeft(playedCard); err != nil { //Checking the turn has a play left for the card.
+		fmt.Println("ERROR:", err)
+		return err
 	}
 
-	switch playedCard.Type { //Checking card type.
-	case "attack": //If card is an attack type. (i.e. damages other marks, places marks etc)
-		switch playedCard.ImpactType { //Determine which slots to effect using impact type.
-		case "singular": //This means a singular slot is effected.
-			//Might have to calculate if it can be played or not (i.e. is slot valid)
-			fmt.Println("Playing singular card.")
-			room.Board.Slots[pMsg.TargetSlotID].AddEffectToSlot(playedCard.MarkEffect, player) //Add card effect to slot.
-		case "multiple": //Means multiple slots get affected.
-			fmt.Println("Playing multiple card.")
-			slotsToAffect := room.Board.GetAffectedSlots(playedCard.ImpactShape, pMsg.TargetSlotID) //Retrieving slots to affect.
-
-			room.Board.ApplyDamageToSlotsFromCard(slotsToAffect, playedCard.MarkEffect)
-
-			fmt.Println("slots to affect: ", slotsToAffect)
+	targets := pMsg.Targets
+	if len(targets) == 0 { //Single target plays can use target_slot.
+		targets = []int{pMsg.TargetSlotID}
+	}
 
-			for i := 0; i < len(slotsToAffect); i++ { //Cycle through slots and add effect.
-				slotsToAffect[i].AddEffectToSlot(playedCard.MarkEffect, player) //Adding effect.
-			}
+	if playedCard.Targeting == TargetNone && len(playedCard.Steps) == 0 { //Untargeted cards resolve the same wherever they are dropped.
+		targets = []int{noTargetSlotID}
+	}
 
-		}
-	case "buff": //If card is a buff type (i.e. effects that add health.)
+	fmt.Println("Target Slots are: ", targets)
 
+	if err := room.Board.ResolveTargets(playedCard, player, targets, room.Rand); err != nil { //Validates each target against its step, nothing changes if one is rejected.
+		fmt.Println("ERROR: Card play rejected:", err)
+		return err
 	}
 
+	player.RemoveFromHand(playedCard) //Played cards go to the discard pile.
+	room.CountPlay(playedCard)
+
 	msg := GameMessage{ //Create game message to send to clients.
 		Type:         "play_card_success", //Setting type to successful card play.
-		TargetSlotID: &pMsg.TargetSlotID,  //Sending the confirmation slot back for success msg.
+		TargetSlotID: &targets[0],         //Sending the confirmation slot back for success msg.
+		Targets:      targets,             //Every slot the card was played on.
+		RemoveCards:  []*Card{playedCard}, //Card to remove from the client's hand.
 	}
 
 	SendMessageToPlayer(player, ConvertMsgToJson(&msg))
 
+	return nil
+
 }
 
-func (b *Board) ApplyDamageToSlotsFromCard(slots []*Slot, mEffect *MarkEffect) { //Applies damage to slots.
+func (b *Board) ApplyDamageToSlotsFromCard(slots []*Slot, mEffect *MarkEffect) []SlotEffect { //Applies damage to slots. Non-pure damage skips protected slots. Returns the destroyed effects.
+
+	destroyed := []SlotEffect{}
 
 	if mEffect.Damage <= 0 { //If effect cannot damage, return from function.
-		return
+		return destroyed
 	}
 
 	for i := 0; i < len(slots); i++ { //Cycle through slots.
+
+		if mEffect.DamageType != DamagePure && slots[i].IsProtected() { //Blockable damage is stopped by protective marks.
+			fmt.Println("Damage blocked on slot", slots[i].ID)
+			continue
+		}
+
 		newEffects := []*MarkEffect{} // Adjust type as needed
 
 		for _, eff := range slots[i].Effects {
 
 			if !eff.IsDestroyable { //If not destroyable, skip damage taking (prevents destruction of marks that are immune to damage)
+				newEffects = append(newEffects, eff)
 				continue
 			}
 
-			eff.Health -= mEffect.Damage
+			eff.TakeDamage(mEffect.Damage, mEffect.DamageType)
 			if eff.Health > 0 {
 				newEffects = append(newEffects, eff)
+				continue
 			}
 			// Otherwise: Effect is dead, so exclude it
+			destroyed = append(destroyed, SlotEffect{Slot: slots[i], Effect: eff})
 		}
 
 		slots[i].Effects = newEffects // Replace with filtered effects.
 	}
 
+	return destroyed
 }
 
-func (b *Board) GetAffectedSlots(shape string, tarSlotID int) []*Slot { //Method that retrieves an array of slots to be affected by the card and it's impact shape.
-
-	retSlots := []*Slot{} //Creating return slot array.
+func (b *Board) GetAffectedSlots(shape string, tarSlotID int, owner uuid.UUID) []*Slot { //Method that retrieves an array of slots to be affected by the shape, seen from the owner's side.
 
 	tSlot := b.ReturnSlotFromID(tarSlotID) //Get a pointer to the targetSlot, to use row and col data.
 
-	switch shape {
-	case "lines": //If the shape is similar to a bomberman.
-		for i := 0; i < len(b.Slots); i++ { //Cycle through slots to determine if affected or not.
-			dRow := abs(b.Slots[i].Row - tSlot.Row) //Getting difference in rows.
-			dCol := abs(b.Slots[i].Col - tSlot.Col) //Getting difference in columns.
-
-			if dRow < 1 && dCol <= 2 {
-				retSlots = append(retSlots, b.Slots[i])
-				continue
-			}
-
-			if dRow <= 2 && dCol < 1 {
-				retSlots = append(retSlots, b.Slots[i])
-				continue
-			}
-		}
-	case "radius": //If the shape is a radius (1 slot around target Slot)
-		for i := 0; i < len(b.Slots); i++ {
-
-			dRow := abs(b.Slots[i].Row - tSlot.Row) //Getting difference in rows.
-			dCol := abs(b.Slots[i].Col - tSlot.Col) //Getting difference in columns.
-
-			if dRow <= 1 && dCol <= 1 {
-				retSlots = append(retSlots, b.Slots[i])
-				continue
-			}
-
-		}
+	if tSlot == nil { //If target is off the board, nothing is affected.
+		return []*Slot{}
+	}
 
+	def, ok := LookupShape(shape)
+	if !ok { //Catalogue validation rejects unknown shapes, so this is only reached by bad data.
+		fmt.Println("ERROR: Unknown impact shape:", shape)
+		return []*Slot{}
 	}
 
-	return retSlots //returning slot array.
+	return b.ShapeSlots(def, tSlot, owner)
 }
 
 func SetEffectSprite() { //This decides if player is naughts or crosses.
@@ -280,80 +266,169 @@ func (b *Board) ReturnSlotFromID(slotID int) *Slot { //Method that returns the s
 
 }
 
-func (rm *Room) CheckBoardWin(b *Board) {
+func (b *Board) ReturnSlotFromPos(row int, col int) *Slot { //Method that returns the slot at the row and column, or nil if out of bounds.
+
+	if row < 0 || row >= b.Height || col < 0 || col >= b.Width {
+		return nil
+	}
+
+	return b.Slots[row*b.Width+col] //Slots are stored row by row.
+
+}
+
+func (b *Board) Config() BoardConfig { //Returns the geometry of the board.
+	return BoardConfig{Width: b.Width, Height: b.Height, WinLength: b.WinLength}
+}
+
+// WinLine is a completed line of marks owned by a single player.
+type WinLine struct {
+	Owner     uuid.UUID //The owner of every mark in the line.
+	Direction string    //The direction of the line (row, col, diag, anti_diag)
+	SlotIDs   []int     //The IDs of the slots in the line, ordered from the start of the line.
+}
+
+var lineDirections = []struct { //Directions a line can run in, as row and column steps.
+	Name string
+	DRow int
+	DCol int
+}{
+	{Name: "row", DRow: 0, DCol: 1},
+	{Name: "col", DRow: 1, DCol: 0},
+	{Name: "diag", DRow: 1, DCol: 1},
+	{Name: "anti_diag", DRow: 1, DCol: -1},
+}
+
+func (rm *Room) CheckBoardWin(b *Board) (*Player, []int, bool) { //Checks the board for a winning line, returns the winner and the winning slot IDs (nil if no winner). Returns true with no winner if more than one player holds a line.
+
+	lines := b.FindCompletedLines(b.WinLength) //Finding all completed lines on the board.
+
+	var winner *Player
+
 	for p := 0; p < len(rm.Players); p++ {
-		winningSlots := []*Slot{}
+		if len(lines[rm.Players[p].ID]) == 0 {
+			continue
+		}
+		if winner != nil { //Both completed a line in the same action, neither wins.
+			fmt.Println("Players", winner.Name, "and", rm.Players[p].Name, "both hold a line, draw.")
+			return nil, nil, true
+		}
+		winner = rm.Players[p]
+	}
 
-		for i := 0; i < len(b.Slots); i++ {
-			for z := 0; z < len(b.Slots[i].Effects); z++ {
-				if b.Slots[i].Effects[z].IsWinEffect && b.Slots[i].Effects[z].Owner == rm.Players[p].ID {
-					winningSlots = append(winningSlots, b.Slots[i])
+	if winner == nil {
+		return nil, nil, false
+	}
+
+	fmt.Println("Player", winner.Name, "wins!")
+
+	return winner, lines[winner.ID][0].SlotIDs, false
+}
+
+func (b *Board) FindCompletedLines(lineLen int) map[uuid.UUID][]WinLine { //Returns every row, column, diagonal and anti-diagonal of lineLen winnable marks, grouped by owner.
+
+	retLines := make(map[uuid.UUID][]WinLine)
+
+	if lineLen <= 0 { //A line must have at least one slot.
+		return retLines
+	}
+
+	for _, slot := range b.Slots { //Each slot is a potential start of a line.
+		for _, owner := range slot.WinOwners() {
+			for _, dir := range lineDirections {
+
+				slotIDs := []int{}
+
+				for step := 0; step < lineLen; step++ { //Walk along the direction, stopping at the edge or an unowned slot.
+					next := b.ReturnSlotFromPos(slot.Row+dir.DRow*step, slot.Col+dir.DCol*step)
+					if next == nil || !next.HasWinMark(owner) {
+						break
+					}
+					slotIDs = append(slotIDs, next.ID)
 				}
-			}
-		}
 
-		for i := 0; i < len(winningSlots); i++ {
-			if SlotWinCheck(winningSlots, i, "null", 1) {
-				fmt.Println("Player", rm.Players[p].Name, "wins!")
-				return
+				if len(slotIDs) == lineLen { //If every slot along the walk was owned, it is a line.
+					retLines[owner] = append(retLines[owner], WinLine{Owner: owner, Direction: dir.Name, SlotIDs: slotIDs})
+				}
 			}
 		}
 	}
+
+	return retLines
 }
 
-func SlotWinCheck(winningSlots []*Slot, i int, winType string, valSlots int) bool { //Might need to test, but should allow for a win.
-	winningType := winType
+func (sl *Slot) HasWinMark(owner uuid.UUID) bool { //Returns true if the slot holds a winnable mark owned by owner.
 
-	for z := i + 1; z < len(winningSlots); z++ {
-		dRow := abs(winningSlots[i].Row - winningSlots[z].Row)
-		dCol := abs(winningSlots[i].Col - winningSlots[z].Col)
+	for _, eff := range sl.Effects {
+		if eff.IsWinEffect && eff.Owner == owner {
+			return true
+		}
+	}
 
-		foundValid := false
+	return false
+}
 
-		if winningType == "col" || winningType == "null" {
-			if dRow == 1 && dCol == 0 {
-				winningType = "col"
-				valSlots++
-				foundValid = true
-			}
-		}
-		if winningType == "row" || winningType == "null" {
-			if dRow == 0 && dCol == 1 {
-				winningType = "row"
-				valSlots++
-				foundValid = true
-			}
+func (sl *Slot) WinOwners() []uuid.UUID { //Returns the owners of winnable marks on the slot, each listed once.
+
+	owners := []uuid.UUID{}
+
+	for _, eff := range sl.Effects {
+		if !eff.IsWinEffect {
+			continue
 		}
-		if winningType == "diag" || winningType == "null" {
-			if dRow == 1 && dCol == 1 {
-				winningType = "diag"
-				valSlots++
-				foundValid = true
+
+		found := false
+		for _, o := range owners {
+			if o == eff.Owner {
+				found = true
+				break
 			}
 		}
 
-		if !foundValid {
-			continue
+		if !found {
+			owners = append(owners, eff.Owner)
 		}
+	}
 
-		if valSlots == 3 {
-			fmt.Println("Game won.")
-			return true
+	return owners
+}
+
+func (b *Board) IsFull() bool { //Returns true if every slot holds a winnable mark.
+
+	for i := 0; i < len(b.Slots); i++ {
+		hasMark := false
+
+		for _, eff := range b.Slots[i].Effects {
+			if eff.IsWinEffect {
+				hasMark = true
+				break
+			}
 		}
 
-		if SlotWinCheck(winningSlots, z, winningType, valSlots) {
-			return true
+		if !hasMark {
+			return false
 		}
 	}
 
-	return false
+	return true
 }
 
-func (rm *Room) SendBoardState() []*Slot {
+func (rm *Room) SendBoardState(viewer *Player) []*Slot { //Returns the board as the viewer may see it. Hidden effects (i.e. mines) are only sent to their owner, a nil viewer (spectators) sees none. Room mutex must be held.
 
 	displayMarks := []*Slot{}
 
-	displayMarks = append(displayMarks, rm.Board.Slots...)
+	for _, sl := range rm.Board.Slots {
+
+		shown := *sl //Copy so the board keeps its hidden effects.
+		shown.Effects = []*MarkEffect{}
+
+		for _, eff := range sl.Effects {
+			if eff.IsDisplayable || (viewer != nil && eff.Owner == viewer.ID) {
+				shown.Effects = append(shown.Effects, eff)
+			}
+		}
+
+		displayMarks = append(displayMarks, &shown)
+	}
 
 	return displayMarks
 
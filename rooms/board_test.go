@@ -0,0 +1,274 @@
+package rooms
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ownerA = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	ownerB = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+)
+
+func testBoard(preset string) *Board { //Returns an empty board built from the preset.
+	b := CreateBoard(BoardPresets[preset])
+	return &b
+}
+
+func placeMarks(b *Board, owner uuid.UUID, slotIDs ...int) { //Places a winnable mark for the owner on each slot, in the order given.
+	for _, id := range slotIDs {
+		sl := b.ReturnSlotFromID(id)
+		sl.Effects = append(sl.Effects, &MarkEffect{Owner: owner, Health: 1, IsWinEffect: true, IsDisplayable: true})
+	}
+}
+
+func placeNonWin(b *Board, owner uuid.UUID, slotIDs ...int) { //Places an effect that does not count towards lines.
+	for _, id := range slotIDs {
+		sl := b.ReturnSlotFromID(id)
+		sl.Effects = append(sl.Effects, &MarkEffect{Owner: owner, Health: 1, IsDisplayable: true})
+	}
+}
+
+func lineIDs(lines []WinLine) [][]int { //Returns the slot IDs of each line, sorted so results compare regardless of order.
+	ids := [][]int{}
+	for _, l := range lines {
+		ids = append(ids, l.SlotIDs)
+	}
+	slices.SortFunc(ids, slices.Compare[[]int])
+	return ids
+}
+
+func TestFindCompletedLines(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		preset string
+		a      []int   //Slots with owner A's marks.
+		b      []int   //Slots with owner B's marks.
+		nonWin []int   //Slots with a non-winnable effect owned by A.
+		want   [][]int //Lines owner A completes.
+	}{
+		//Every line on the classic board.
+		{name: "row 0", preset: "classic", a: []int{0, 1, 2}, want: [][]int{{0, 1, 2}}},
+		{name: "row 1", preset: "classic", a: []int{3, 4, 5}, want: [][]int{{3, 4, 5}}},
+		{name: "row 2", preset: "classic", a: []int{6, 7, 8}, want: [][]int{{6, 7, 8}}},
+		{name: "col 0", preset: "classic", a: []int{0, 3, 6}, want: [][]int{{0, 3, 6}}},
+		{name: "col 1", preset: "classic", a: []int{1, 4, 7}, want: [][]int{{1, 4, 7}}},
+		{name: "col 2", preset: "classic", a: []int{2, 5, 8}, want: [][]int{{2, 5, 8}}},
+		{name: "diagonal", preset: "classic", a: []int{0, 4, 8}, want: [][]int{{0, 4, 8}}},
+		{name: "anti-diagonal", preset: "classic", a: []int{2, 4, 6}, want: [][]int{{2, 4, 6}}},
+
+		//Lines are found whatever order the marks were placed in.
+		{name: "row placed out of order", preset: "classic", a: []int{2, 0, 1}, want: [][]int{{0, 1, 2}}},
+		{name: "anti-diagonal placed out of order", preset: "classic", a: []int{6, 2, 4}, want: [][]int{{2, 4, 6}}},
+		{name: "row and column sharing a corner", preset: "classic", a: []int{0, 1, 2, 3, 6}, want: [][]int{{0, 1, 2}, {0, 3, 6}}},
+
+		//Near misses.
+		{name: "top corners and centre", preset: "classic", a: []int{0, 4, 2}, want: [][]int{}},
+		{name: "bent anti-diagonal down", preset: "classic", a: []int{2, 4, 7}, want: [][]int{}},
+		{name: "bent anti-diagonal across", preset: "classic", a: []int{2, 4, 3}, want: [][]int{}},
+		{name: "bent diagonal", preset: "classic", a: []int{0, 4, 5}, want: [][]int{}},
+		{name: "row wrapping to the next row", preset: "classic", a: []int{1, 2, 3}, want: [][]int{}},
+		{name: "row ending past the edge", preset: "classic", a: []int{4, 5, 6}, want: [][]int{}},
+		{name: "two in a row", preset: "classic", a: []int{0, 1}, want: [][]int{}},
+		{name: "empty board", preset: "classic", want: [][]int{}},
+
+		//Mixed owners and effects that are not marks.
+		{name: "row finished by the opponent", preset: "classic", a: []int{0, 1}, b: []int{2}, want: [][]int{}},
+		{name: "column split between owners", preset: "classic", a: []int{0, 6}, b: []int{3}, want: [][]int{}},
+		{name: "row finished by a non-win effect", preset: "classic", a: []int{0, 1}, nonWin: []int{2}, want: [][]int{}},
+		{name: "shared slot still counts", preset: "classic", a: []int{0, 1, 2}, b: []int{1}, want: [][]int{{0, 1, 2}}},
+
+		//Four in a row.
+		{name: "four: row", preset: "four", a: []int{4, 5, 6, 7}, want: [][]int{{4, 5, 6, 7}}},
+		{name: "four: column", preset: "four", a: []int{3, 7, 11, 15}, want: [][]int{{3, 7, 11, 15}}},
+		{name: "four: diagonal", preset: "four", a: []int{0, 5, 10, 15}, want: [][]int{{0, 5, 10, 15}}},
+		{name: "four: anti-diagonal", preset: "four", a: []int{3, 6, 9, 12}, want: [][]int{{3, 6, 9, 12}}},
+		{name: "four: three is not enough", preset: "four", a: []int{0, 1, 2}, want: [][]int{}},
+		{name: "four: three-long diagonal", preset: "four", a: []int{1, 6, 11}, want: [][]int{}},
+		{name: "four: row wrapping", preset: "four", a: []int{2, 3, 4, 5}, want: [][]int{}},
+
+		//Four in a row on a 5x5 board.
+		{name: "gomoku: row from the edge", preset: "gomoku_lite", a: []int{0, 1, 2, 3}, want: [][]int{{0, 1, 2, 3}}},
+		{name: "gomoku: row from the middle", preset: "gomoku_lite", a: []int{6, 7, 8, 9}, want: [][]int{{6, 7, 8, 9}}},
+		{name: "gomoku: five in a row holds two lines", preset: "gomoku_lite", a: []int{20, 21, 22, 23, 24}, want: [][]int{{20, 21, 22, 23}, {21, 22, 23, 24}}},
+		{name: "gomoku: column", preset: "gomoku_lite", a: []int{4, 9, 14, 19}, want: [][]int{{4, 9, 14, 19}}},
+		{name: "gomoku: off-centre diagonal", preset: "gomoku_lite", a: []int{1, 7, 13, 19}, want: [][]int{{1, 7, 13, 19}}},
+		{name: "gomoku: off-centre anti-diagonal", preset: "gomoku_lite", a: []int{9, 13, 17, 21}, want: [][]int{{9, 13, 17, 21}}},
+		{name: "gomoku: gap in the row", preset: "gomoku_lite", a: []int{0, 1, 3, 4}, want: [][]int{}},
+		{name: "gomoku: diagonal wrapping", preset: "gomoku_lite", a: []int{3, 9, 15, 21}, want: [][]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			b := testBoard(tt.preset)
+			placeMarks(b, ownerA, tt.a...)
+			placeMarks(b, ownerB, tt.b...)
+			placeNonWin(b, ownerA, tt.nonWin...)
+
+			lines := b.FindCompletedLines(b.WinLength)
+
+			if got := lineIDs(lines[ownerA]); !slices.EqualFunc(got, tt.want, slices.Equal[[]int]) {
+				t.Errorf("lines = %v, want %v", got, tt.want)
+			}
+
+			if got := lines[ownerB]; len(got) != 0 {
+				t.Errorf("opponent lines = %v, want none", lineIDs(got))
+			}
+		})
+	}
+}
+
+func TestFindCompletedLinesEveryLine(t *testing.T) { //Every straight line of WinLength slots on each preset is found on its own.
+
+	for name, cfg := range BoardPresets {
+		t.Run(name, func(t *testing.T) {
+
+			found := 0
+
+			for row := 0; row < cfg.Height; row++ {
+				for col := 0; col < cfg.Width; col++ {
+					for _, dir := range lineDirections {
+
+						endRow := row + dir.DRow*(cfg.WinLength-1)
+						endCol := col + dir.DCol*(cfg.WinLength-1)
+						if endRow < 0 || endRow >= cfg.Height || endCol < 0 || endCol >= cfg.Width {
+							continue
+						}
+
+						ids := []int{}
+						for step := 0; step < cfg.WinLength; step++ {
+							ids = append(ids, (row+dir.DRow*step)*cfg.Width+col+dir.DCol*step)
+						}
+
+						b := testBoard(name)
+						placeMarks(b, ownerA, ids...)
+
+						lines := b.FindCompletedLines(b.WinLength)[ownerA]
+						if len(lines) != 1 || !slices.Equal(lines[0].SlotIDs, ids) || lines[0].Direction != dir.Name {
+							t.Errorf("%s line %v: got %v", dir.Name, ids, lines)
+						}
+						found++
+					}
+				}
+			}
+
+			if found == 0 {
+				t.Fatalf("no lines fit on %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestCheckBoardWin(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		a          []int
+		b          []int
+		wantWinner uuid.UUID
+		wantLine   []int
+		wantTied   bool
+	}{
+		{name: "no line", a: []int{0, 1}, b: []int{3, 4}},
+		{name: "first player's line", a: []int{0, 1, 2}, b: []int{3, 4}, wantWinner: ownerA, wantLine: []int{0, 1, 2}},
+		{name: "second player's line", a: []int{0, 1}, b: []int{3, 4, 5}, wantWinner: ownerB, wantLine: []int{3, 4, 5}},
+		{name: "both players hold a line", a: []int{0, 1, 2}, b: []int{6, 7, 8}, wantTied: true},
+		{name: "both lines through a shared slot", a: []int{0, 4, 8}, b: []int{2, 4, 6}, wantTied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			b := testBoard("classic")
+			placeMarks(b, ownerA, tt.a...)
+			placeMarks(b, ownerB, tt.b...)
+
+			room := &Room{Board: b, Players: []*Player{{ID: ownerA}, {ID: ownerB}}}
+
+			winner, line, tied := room.CheckBoardWin(b)
+
+			var winnerID uuid.UUID
+			if winner != nil {
+				winnerID = winner.ID
+			}
+
+			if winnerID != tt.wantWinner || !slices.Equal(line, tt.wantLine) || tied != tt.wantTied {
+				t.Errorf("CheckBoardWin = %s, %v, %t, want %s, %v, %t", winnerID, line, tied, tt.wantWinner, tt.wantLine, tt.wantTied)
+			}
+		})
+	}
+}
+
+func TestBothLinesEndInDraw(t *testing.T) {
+
+	room := NewRoom(RoomOptions{Board: BoardPresets["classic"]}, 1)
+	room.AddPlayer(newReplayPlayer(ownerA, "a"))
+	room.AddPlayer(newReplayPlayer(ownerB, "b"))
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if err := room.Transition(StateInProgress); err != nil {
+		t.Fatalf("starting game: %v", err)
+	}
+
+	placeMarks(room.Board, ownerA, 0, 1, 2)
+	placeMarks(room.Board, ownerB, 6, 7, 8)
+
+	if !room.CheckGameOver() {
+		t.Fatalf("game not over with both players holding a line")
+	}
+
+	if room.Result.Reason != ReasonDraw || room.Result.WinnerID != uuid.Nil {
+		t.Errorf("result = %+v, want a draw", room.Result)
+	}
+}
+
+func TestBoardStateHidesEnemyTraps(t *testing.T) {
+
+	b := testBoard("classic")
+	placeMarks(b, ownerA, 0)
+	b.ReturnSlotFromID(4).Effects = []*MarkEffect{{Owner: ownerA, Health: 1, Trigger: TriggerOnPlace, TriggerDamage: 1}} //A's mine.
+
+	a, opp := &Player{ID: ownerA}, &Player{ID: ownerB}
+	room := &Room{Board: b, Players: []*Player{a, opp}}
+
+	tests := []struct {
+		name      string
+		viewer    *Player
+		wantMine  bool
+		wantMarks int //Effects sent in total.
+	}{
+		{name: "owner", viewer: a, wantMine: true, wantMarks: 2},
+		{name: "opponent", viewer: opp, wantMine: false, wantMarks: 1},
+		{name: "spectator", viewer: nil, wantMine: false, wantMarks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			sent := 0
+			mine := false
+			for _, sl := range room.SendBoardState(tt.viewer) {
+				for _, eff := range sl.Effects {
+					sent++
+					if eff.Trigger != "" {
+						mine = true
+					}
+				}
+			}
+
+			if mine != tt.wantMine || sent != tt.wantMarks {
+				t.Errorf("mine sent = %t with %d effects, want %t with %d", mine, sent, tt.wantMine, tt.wantMarks)
+			}
+		})
+	}
+
+	if len(b.ReturnSlotFromID(4).Effects) != 1 {
+		t.Errorf("building the payload removed the mine from the board")
+	}
+}
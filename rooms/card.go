@@ -1,24 +1,30 @@
 package rooms
 
 import (
+	"math/rand"
 	"sync"
 
 	"github.com/google/uuid"
 )
 
 type Card struct {
-	Type        string  //Card type (i.e. attack)
-	Name        string  //Card name (should be unique for each card)
-	Description string  //Card description
-	Rarity      float64 //Card Rarity, all card rarities should add to 1.0
+	InstanceID  uuid.UUID //Unique ID of this drawn copy of the card, uuid.Nil for catalogue cards.
+	Type        string    //Card type (i.e. attack)
+	Name        string    //Card name (should be unique for each card)
+	Description string    //Card description
+	Rarity      float64   //Card Rarity, all card rarities should add to 1.0
 	GraphicPath string
 	MarkerPath  string
-	ImpactType  string      //Impact Type decides if many or singular slots are effected.
-	ImpactShape string      //Impact Shape is the shape of the effect. (i.e. does it strike rows or a radius all around etc)
-	MarkEffect  *MarkEffect //The effect the card has on the slots.
+	ImpactType  string       //Impact Type decides if many or singular slots are effected.
+	ImpactShape string       //Impact Shape is the shape of the effect. (i.e. does it strike rows or a radius all around etc)
+	Targeting   string       //Which slots the card can be played on (any, empty_slot, enemy_mark, own_mark, none)
+	Steps       []TargetStep //Ordered targets of multi-target cards, empty for single target cards.
+	Action      string       //What the card does with its targets (swap, move), empty resolves the effect on each target.
+	MarkEffect  *MarkEffect  //The effect the card has on the slots.
 }
 
 type MarkEffect struct { //Mark Effects are the effects of the marks (These typically involving adding or subtracting health). Each card has a mark (effect).
+	InstanceID    uuid.UUID //Unique ID of this placed mark, uuid.Nil for catalogue effects.
 	Owner         uuid.UUID //The owner of the mark.
 	Health        int       // The amount of health a mark has.
 	GraphicPath   string    //The path of the graphic (mark) to show.
@@ -29,111 +35,72 @@ type MarkEffect struct { //Mark Effects are the effects of the marks (These typi
 	DamageType    string    //Used to check if damage is pure (cannot be blocked)
 	IsWinEffect   bool      //If the effect can be considered as winnable (i.e. it is a valid mark.)
 	IsDisplayable bool
+
+	Heal         int //Buff: health added to the player's own marks.
+	Shield       int //Buff: shield points granted to the player's own marks, absorbs non-pure damage.
+	FortifyTurns int //Buff: turns the player's own marks cannot be destroyed by non-pure damage.
+	BuffTurns    int //Buff: turns a granted shield lasts, 0 lasts until it is used up.
+
+	Duration      int    //Turns the effect lasts once placed, 0 is permanent. Counts down on placed effects.
+	FuseTurns     int    //Turns until the effect detonates, 0 never. Counts down on placed effects.
+	FuseDamage    int    //Damage dealt when the fuse detonates, using DamageType.
+	FuseShape     string //Impact shape of the detonation, "null" only hits the effect's slot.
+	DamagePerTurn int    //Damage dealt to the other marks on the slot every turn, using DamageType.
+
+	Trigger        string //Event the effect reacts to once placed (on_place, on_destroy, on_turn_start, on_target), empty for none.
+	TriggerDamage  int    //Damage dealt when the trigger fires, using DamageType.
+	TriggerShape   string //Impact shape the trigger hits or watches, "null" only covers the effect's slot.
+	TriggerCharges int    //Times the trigger can fire before the effect is removed, 0 is unlimited.
+	TriggerCancels bool   //If an on_target trigger cancels the enemy card.
+
+	ShieldPoints   int //Shield points currently on the mark.
+	ShieldTurns    int //Turns left on the mark's shield, 0 never expires.
+	FortifiedTurns int //Turns left the mark is fortified.
 }
 
-var cards = []*Card{}    //An array that stores all possible card types.
+var cards = []*Card{}    //An array that stores all possible card types. Loaded from the card catalogue files.
+var cardsVersion string  //The version (content hash) of the loaded card catalogue.
 var cardsMu sync.RWMutex //Read-Write Mutex allows multiple readers, one write.
 
-func CreateCards() []*Card { //Creating all possible cards.
-
-	cardsMu.Lock()
-	cardsToRet := []*Card{}
-
-	crdDefault := Card{Type: "Null", Name: "Default", //Default card, used for nothing but testing.
-		Description: "This card does nothing.",
-		Rarity:      0.0,
-		GraphicPath: "src/card_test_mark.png",
-		MarkerPath:  "src/naught.svg",
-		ImpactType:  "singular",
-		ImpactShape: "null",
-		MarkEffect: &MarkEffect{
-			Owner:         uuid.Nil,
-			Health:        1,
-			GraphicPath:   "src/naught.svg",
-			Damage:        0,
-			IsDestroyable: false,
-			IsStackable:   false,
-		},
-	}
+func CurrentCatalogue() (string, []*Card) { //Returns the version and cards of the loaded catalogue. The returned slice is never modified, reloads swap in a new one.
 
-	cardsToRet = append(cardsToRet, &crdDefault)
-
-	crdMark := Card{Type: "attack", //The mark card, used to place a mark.
-		Name:        "Mark",
-		Description: "Place a mark in a square.",
-		Rarity:      1.0,
-		GraphicPath: "src/card_test_mark.png",
-		MarkerPath:  "src/naught.svg",
-		ImpactType:  "singular",
-		ImpactShape: "null",
-		MarkEffect: &MarkEffect{
-			Owner:         uuid.Nil,
-			Health:        1,
-			GraphicPath:   "src/naught.svg",
-			Damage:        0, //Cannot damage card.
-			IsDestroyable: true,
-			IsStackable:   true,
-			IsBlocking:    true,
-			DamageType:    "place", //"place" means it can be blocked and the slot must not have a blocking mark effect (i.e. an opponent mark, but it can have an invisible mark.)
-			IsWinEffect:   true,
-			IsDisplayable: true,
-		},
-	}
+	cardsMu.RLock()
+	defer cardsMu.RUnlock()
 
-	cardsToRet = append(cardsToRet, &crdMark) //Adding to card list.
-
-	crdBomb := Card{Type: "attack", //The mark card, used to place a mark.
-		Name:        "Bomb",
-		Description: "Destroys all marks in a 1 slot radius.",
-		Rarity:      0.0,
-		GraphicPath: "src/card_test_mark.png",
-		MarkerPath:  "src/naught.svg",
-		ImpactType:  "multiple",
-		ImpactShape: "radius",
-		MarkEffect: &MarkEffect{
-			Owner:         uuid.Nil,
-			Health:        0,
-			GraphicPath:   "src/naught.svg",
-			Damage:        100,
-			IsDestroyable: false,
-			IsStackable:   false,
-			IsBlocking:    false,
-			DamageType:    "pure", //"pure" means it cannot be blocked or affected by protective buffs.
-			IsWinEffect:   false,
-			IsDisplayable: false,
-		},
-	}
+	return cardsVersion, cards
+}
+
+func (c *Card) NewInstance(rng *rand.Rand) *Card { //Returns a copy of the catalogue card with its own instance ID and mark effect. IDs come from rng so seeded games are reproducible.
+
+	inst := *c //Copying card values.
+	inst.InstanceID = NewInstanceID(rng)
 
-	cardsToRet = append(cardsToRet, &crdBomb) //Adding to card list.
-
-	crdDyn := Card{Type: "attack", //The mark card, used to place a mark.
-		Name:        "Dynamite",
-		Description: "Destroys all marks in the same row and column.",
-		Rarity:      0.5,
-		GraphicPath: "src/card_test_mark.png",
-		MarkerPath:  "src/naught.svg",
-		ImpactType:  "multiple",
-		ImpactShape: "lines",
-		MarkEffect: &MarkEffect{
-			Owner:         uuid.Nil,
-			Health:        0,
-			GraphicPath:   "src/naught.svg",
-			Damage:        100,
-			IsDestroyable: false,
-			IsStackable:   false,
-			IsBlocking:    false,
-			DamageType:    "pure",
-			IsWinEffect:   false,
-			IsDisplayable: false,
-		},
+	if c.MarkEffect != nil {
+		inst.MarkEffect = c.MarkEffect.NewInstance(uuid.Nil, rng)
 	}
 
-	cardsToRet = append(cardsToRet, &crdDyn) //Adding to card list.
+	return &inst
+}
+
+func (m *MarkEffect) NewInstance(owner uuid.UUID, rng *rand.Rand) *MarkEffect { //Returns a copy of the effect with its own instance ID and owner, so placed marks never share health.
 
-	cards = cardsToRet
+	inst := *m //Copying effect values.
+	inst.InstanceID = NewInstanceID(rng)
+	inst.Owner = owner
+
+	return &inst
+}
 
-	cardsMu.Unlock()
+func NewInstanceID(rng *rand.Rand) uuid.UUID { //Returns a new ID read from rng, or a random ID if rng is nil.
 
-	return cardsToRet
+	if rng == nil {
+		return uuid.New()
+	}
+
+	id, err := uuid.NewRandomFromReader(rng)
+	if err != nil { //rand.Rand reads never fail.
+		return uuid.New()
+	}
 
+	return id
 }
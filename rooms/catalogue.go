@@ -0,0 +1,485 @@
+package rooms
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CardDefinition is the file format of a single card in the card catalogue.
+// Each catalogue file holds a JSON array of card definitions.
+type CardDefinition struct {
+	Type        string                 `json:"type"`         //Card type (i.e. attack)
+	Name        string                 `json:"name"`         //Card name, must be unique across all files.
+	Description string                 `json:"description"`  //Card description
+	Rarity      float64                `json:"rarity"`       //Card Rarity, all card rarities should add to 1.0
+	GraphicPath string                 `json:"graphic_path"` //The card graphic.
+	MarkerPath  string                 `json:"marker_path"`  //The marker graphic.
+	ImpactType  string                 `json:"impact_type"`  //singular or multiple.
+	ImpactShape string                 `json:"impact_shape"` //The shape of the effect for multiple impact cards.
+	Targeting   string                 `json:"targeting"`    //Which slots the card can be played on, defaults to any.
+	Steps       []TargetStepDefinition `json:"steps"`        //Ordered targets of multi-target cards, replaces targeting.
+	Action      string                 `json:"action"`       //What the card does with its targets (swap, move), empty resolves the effect on each target.
+	MarkEffect  *MarkEffectDefinition  `json:"mark_effect"`  //The effect the card has on the slots.
+}
+
+// TargetStepDefinition is the file format of one target of a multi-target card.
+type TargetStepDefinition struct {
+	Targeting string `json:"targeting"` //Which slots the step can target.
+	Range     int    `json:"range"`     //Max distance from the previous step's target, 0 is unlimited.
+}
+
+// MarkEffectDefinition is the file format of a card's mark effect.
+type MarkEffectDefinition struct {
+	Health        int    `json:"health"`
+	GraphicPath   string `json:"graphic_path"`
+	Damage        int    `json:"damage"`
+	IsDestroyable bool   `json:"is_destroyable"`
+	IsStackable   bool   `json:"is_stackable"`
+	IsBlocking    bool   `json:"is_blocking"`
+	DamageType    string `json:"damage_type"`
+	IsWinEffect   bool   `json:"is_win_effect"`
+	IsDisplayable bool   `json:"is_displayable"`
+	Heal          int    `json:"heal"`
+	Shield        int    `json:"shield"`
+	FortifyTurns  int    `json:"fortify_turns"`
+	BuffTurns     int    `json:"buff_turns"`
+	Duration      int    `json:"duration"`
+	FuseTurns     int    `json:"fuse_turns"`
+	FuseDamage    int    `json:"fuse_damage"`
+	FuseShape     string `json:"fuse_shape"`
+	DamagePerTurn int    `json:"damage_per_turn"`
+
+	Trigger        string `json:"trigger"`
+	TriggerDamage  int    `json:"trigger_damage"`
+	TriggerShape   string `json:"trigger_shape"`
+	TriggerCharges int    `json:"trigger_charges"`
+	TriggerCancels bool   `json:"trigger_cancels"`
+}
+
+const catalogueExt = ".json"         //Extension of card catalogue files.
+const rarityTolerance float64 = 1e-6 //Allowed float error when summing rarities.
+
+var validCardTypes = []string{"Null", "attack", "buff"}
+var validImpactTypes = []string{"singular", "multiple"}
+
+// CatalogueError points at the file and line of an invalid card definition.
+type CatalogueError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *CatalogueError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.File, e.Err)
+}
+
+func (e *CatalogueError) Unwrap() error {
+	return e.Err
+}
+
+type locatedDefinition struct { //A definition with the position it was read from.
+	Def  CardDefinition
+	File string
+	Line int
+}
+
+func LoadCardsFromDir(dir string) (string, error) { //Loads and validates every catalogue file in dir, then atomically replaces the global cards. Returns the new version.
+
+	version, cardsToRet, err := ReadCatalogueDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	cardsMu.Lock()
+	cards = cardsToRet //Swapping the slice, rooms holding the old slice keep using it.
+	cardsVersion = version
+	cardsMu.Unlock()
+
+	fmt.Println("Loaded", len(cardsToRet), "cards from", dir, "version", version)
+
+	return version, nil
+}
+
+func ReadCatalogueDir(dir string) (string, []*Card, error) { //Reads and validates every catalogue file in dir without touching the global cards. Returns the catalogue version and cards.
+
+	files, err := catalogueFiles(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	defs := []locatedDefinition{}
+	hash := sha256.New() //The version is a hash of every file's name and content.
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", nil, &CatalogueError{File: file, Err: err}
+		}
+
+		hash.Write([]byte(filepath.Base(file)))
+		hash.Write(data)
+
+		fileDefs, err := readCatalogueFile(file, data)
+		if err != nil {
+			return "", nil, err
+		}
+		defs = append(defs, fileDefs...)
+	}
+
+	cardsToRet, err := buildCatalogue(defs)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:12], cardsToRet, nil
+}
+
+func catalogueFiles(dir string) ([]string, error) { //Returns the sorted catalogue files in dir.
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading card directory: %w", err)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), catalogueExt) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files) //Load in a stable order so duplicate errors and versions are reproducible.
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s card files found in %s", catalogueExt, dir)
+	}
+
+	return files, nil
+}
+
+func StartCatalogueWatcher(dir string, freq time.Duration) { //Polls the catalogue directory and reloads the cards when a file changes.
+	go func() {
+
+		lastStamp := catalogueStamp(dir)
+
+		ticker := time.NewTicker(freq)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+
+			stamp := catalogueStamp(dir)
+			if stamp == lastStamp { //Nothing changed.
+				continue
+			}
+			lastStamp = stamp
+
+			fmt.Println("Card catalogue changed, reloading...")
+
+			if _, err := LoadCardsFromDir(dir); err != nil { //Keep the current catalogue if the new one is invalid.
+				fmt.Println("Card catalogue reload failed:", err)
+			}
+		}
+
+	}()
+}
+
+func catalogueStamp(dir string) string { //Returns a string that changes whenever a catalogue file is added, removed or modified.
+
+	files, err := catalogueFiles(dir)
+	if err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s|%d|%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return sb.String()
+}
+
+func readCatalogueFile(file string, data []byte) ([]locatedDefinition, error) { //Decodes a single catalogue file, recording the line of each card.
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() //Typos in field names should fail rather than be ignored.
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, jsonError(file, data, dec, err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, &CatalogueError{File: file, Line: 1, Err: errors.New("expected a JSON array of cards")}
+	}
+
+	defs := []locatedDefinition{}
+
+	for dec.More() {
+		line := lineAt(data, nextValueOffset(data, dec.InputOffset()))
+
+		var def CardDefinition
+		if err := dec.Decode(&def); err != nil {
+			return nil, jsonError(file, data, dec, err)
+		}
+
+		defs = append(defs, locatedDefinition{Def: def, File: file, Line: line})
+	}
+
+	if _, err := dec.Token(); err != nil { //Reading the closing bracket.
+		return nil, jsonError(file, data, dec, err)
+	}
+
+	return defs, nil
+}
+
+func buildCatalogue(defs []locatedDefinition) ([]*Card, error) { //Validates definitions and converts them into cards.
+
+	cardsToRet := []*Card{}
+	names := make(map[string]locatedDefinition)
+	totalRarity := 0.0
+	var rarityAt locatedDefinition //The card that took the rarity sum past 1.0, or else the last card.
+
+	for _, ld := range defs {
+
+		if err := ValidateCardDefinition(&ld.Def); err != nil {
+			return nil, &CatalogueError{File: ld.File, Line: ld.Line, Err: err}
+		}
+
+		if prev, ok := names[ld.Def.Name]; ok { //Card names must be unique.
+			return nil, &CatalogueError{File: ld.File, Line: ld.Line,
+				Err: fmt.Errorf("duplicate card name %q (first defined at %s:%d)", ld.Def.Name, prev.File, prev.Line)}
+		}
+		names[ld.Def.Name] = ld
+
+		if totalRarity <= 1.0+rarityTolerance { //Stops moving once the sum is past 1.0.
+			rarityAt = ld
+		}
+		totalRarity += ld.Def.Rarity
+
+		cardsToRet = append(cardsToRet, ld.Def.ToCard())
+	}
+
+	if len(cardsToRet) == 0 {
+		return nil, errors.New("card catalogue is empty")
+	}
+
+	if math.Abs(totalRarity-1.0) > rarityTolerance {
+		return nil, &CatalogueError{File: rarityAt.File, Line: rarityAt.Line,
+			Err: fmt.Errorf("card rarities sum to %g, expected 1.0", totalRarity)}
+	}
+
+	return cardsToRet, nil
+}
+
+func ValidateCardDefinition(def *CardDefinition) error { //Checks a single definition for missing or unknown values.
+
+	if def.Name == "" {
+		return errors.New("card is missing a name")
+	}
+	if !contains(validCardTypes, def.Type) {
+		return fmt.Errorf("card %q has unknown type %q", def.Name, def.Type)
+	}
+	if !contains(validImpactTypes, def.ImpactType) {
+		return fmt.Errorf("card %q has unknown impact type %q", def.Name, def.ImpactType)
+	}
+	if !isShape(def.ImpactShape) {
+		return fmt.Errorf("card %q has unknown impact shape %q (known shapes: %s)", def.Name, def.ImpactShape, strings.Join(ShapeNames(), ", "))
+	}
+	if def.Targeting != "" && !contains(validTargetings, def.Targeting) {
+		return fmt.Errorf("card %q has unknown targeting %q", def.Name, def.Targeting)
+	}
+	if def.Targeting == TargetNone && def.ImpactType != "multiple" {
+		return fmt.Errorf("card %q has no target so needs impact type multiple", def.Name)
+	}
+	if shape, ok := LookupShape(def.ImpactShape); ok && def.Targeting == TargetNone && shape.UsesTarget() {
+		return fmt.Errorf("card %q has no target but impact shape %q depends on the target", def.Name, def.ImpactShape)
+	}
+	if !contains(validCardActions, def.Action) {
+		return fmt.Errorf("card %q has unknown action %q", def.Name, def.Action)
+	}
+	if len(def.Steps) > 0 && def.Targeting != "" {
+		return fmt.Errorf("card %q sets both targeting and steps", def.Name)
+	}
+	if def.Action != ActionEffect && len(def.Steps) != 2 {
+		return fmt.Errorf("card %q with action %q needs 2 steps", def.Name, def.Action)
+	}
+	for i, step := range def.Steps {
+		if !contains(validTargetings, step.Targeting) || step.Targeting == TargetNone {
+			return fmt.Errorf("card %q step %d has invalid targeting %q", def.Name, i+1, step.Targeting)
+		}
+		if step.Range < 0 {
+			return fmt.Errorf("card %q step %d has a negative range", def.Name, i+1)
+		}
+	}
+	if def.Rarity < 0 || def.Rarity > 1 {
+		return fmt.Errorf("card %q has rarity %g outside [0, 1]", def.Name, def.Rarity)
+	}
+	if def.MarkEffect == nil {
+		return fmt.Errorf("card %q is missing a mark_effect", def.Name)
+	}
+	if !contains(validDamageTypes, def.MarkEffect.DamageType) {
+		return fmt.Errorf("card %q has unknown damage type %q", def.Name, def.MarkEffect.DamageType)
+	}
+	if eff := def.MarkEffect; eff.Heal < 0 || eff.Shield < 0 || eff.FortifyTurns < 0 || eff.BuffTurns < 0 {
+		return fmt.Errorf("card %q has a negative buff value", def.Name)
+	}
+	if eff := def.MarkEffect; eff.Duration < 0 || eff.FuseTurns < 0 || eff.FuseDamage < 0 || eff.DamagePerTurn < 0 {
+		return fmt.Errorf("card %q has a negative duration, fuse or damage over time", def.Name)
+	}
+	if eff := def.MarkEffect; eff.FuseShape != "" && !isShape(eff.FuseShape) {
+		return fmt.Errorf("card %q has unknown fuse shape %q (known shapes: %s)", def.Name, eff.FuseShape, strings.Join(ShapeNames(), ", "))
+	}
+	if eff := def.MarkEffect; !contains(validTriggers, eff.Trigger) {
+		return fmt.Errorf("card %q has unknown trigger %q", def.Name, eff.Trigger)
+	}
+	if eff := def.MarkEffect; eff.TriggerShape != "" && !isShape(eff.TriggerShape) {
+		return fmt.Errorf("card %q has unknown trigger shape %q (known shapes: %s)", def.Name, eff.TriggerShape, strings.Join(ShapeNames(), ", "))
+	}
+	if eff := def.MarkEffect; eff.TriggerDamage < 0 || eff.TriggerCharges < 0 {
+		return fmt.Errorf("card %q has a negative trigger damage or charges", def.Name)
+	}
+	if eff := def.MarkEffect; eff.Trigger == "" && (eff.TriggerDamage > 0 || eff.TriggerCancels) {
+		return fmt.Errorf("card %q has trigger values but no trigger", def.Name)
+	}
+	if eff := def.MarkEffect; eff.TriggerCancels && eff.Trigger != TriggerOnTarget {
+		return fmt.Errorf("card %q can only cancel cards with an %s trigger", def.Name, TriggerOnTarget)
+	}
+	if eff := def.MarkEffect; def.Type == "buff" && eff.Heal == 0 && eff.Shield == 0 && eff.FortifyTurns == 0 {
+		return fmt.Errorf("buff card %q has no heal, shield or fortify_turns", def.Name)
+	}
+
+	return nil
+}
+
+func (def *CardDefinition) ToCard() *Card { //Converts a definition into a catalogue card.
+
+	eff := def.MarkEffect
+
+	targeting := def.Targeting
+	if targeting == "" {
+		targeting = TargetAny
+	}
+
+	var steps []TargetStep
+	for _, step := range def.Steps {
+		steps = append(steps, TargetStep{Targeting: step.Targeting, Range: step.Range})
+	}
+
+	return &Card{
+		Type:        def.Type,
+		Name:        def.Name,
+		Description: def.Description,
+		Rarity:      def.Rarity,
+		GraphicPath: def.GraphicPath,
+		MarkerPath:  def.MarkerPath,
+		ImpactType:  def.ImpactType,
+		ImpactShape: def.ImpactShape,
+		Targeting:   targeting,
+		Steps:       steps,
+		Action:      def.Action,
+		MarkEffect: &MarkEffect{
+			Health:        eff.Health,
+			GraphicPath:   eff.GraphicPath,
+			Damage:        eff.Damage,
+			IsDestroyable: eff.IsDestroyable,
+			IsStackable:   eff.IsStackable,
+			IsBlocking:    eff.IsBlocking,
+			DamageType:    eff.DamageType,
+			IsWinEffect:   eff.IsWinEffect,
+			IsDisplayable: eff.IsDisplayable,
+			Heal:          eff.Heal,
+			Shield:        eff.Shield,
+			FortifyTurns:  eff.FortifyTurns,
+			BuffTurns:     eff.BuffTurns,
+			Duration:      eff.Duration,
+			FuseTurns:     eff.FuseTurns,
+			FuseDamage:    eff.FuseDamage,
+			FuseShape:     eff.FuseShape,
+			DamagePerTurn: eff.DamagePerTurn,
+
+			Trigger:        eff.Trigger,
+			TriggerDamage:  eff.TriggerDamage,
+			TriggerShape:   eff.TriggerShape,
+			TriggerCharges: eff.TriggerCharges,
+			TriggerCancels: eff.TriggerCancels,
+		},
+	}
+}
+
+//----------------------------------------------------------------------------------------
+//---------------------------------Utility Functions--------------------------------------
+//----------------------------------------------------------------------------------------
+
+func jsonError(file string, data []byte, dec *json.Decoder, err error) error { //Wraps a decode error with the line it happened on.
+
+	offset := dec.InputOffset()
+
+	var synErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &synErr):
+		offset = synErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		offset = int64(len(data))
+	}
+
+	return &CatalogueError{File: file, Line: lineAt(data, offset), Err: err}
+}
+
+func nextValueOffset(data []byte, offset int64) int64 { //Skips whitespace and commas to find where the next value starts.
+
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+
+	return offset
+}
+
+func lineAt(data []byte, offset int64) int { //Returns the 1-based line number of the byte offset.
+
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func isShape(name string) bool { //Returns true if the shape is registered.
+	_, ok := LookupShape(name)
+	return ok
+}
+
+func contains(list []string, val string) bool { //Returns true if val is in list.
+
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package rooms
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func cardJSON(name string, rarity float64, shape string) string { //Returns a one line catalogue entry for a mark card.
+	return fmt.Sprintf(`{"type": "attack", "name": %q, "rarity": %g, "impact_type": "singular", "impact_shape": %q, "targeting": "empty_slot", "mark_effect": {"health": 1, "damage_type": "place", "is_win_effect": true}}`, name, rarity, shape)
+}
+
+func catalogueFile(cards ...string) string { //Returns a catalogue file with one card per line, the first on line 2.
+	return "[\n" + strings.Join(cards, ",\n") + "\n]\n"
+}
+
+func TestReadCatalogueDirErrors(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantAt  string //File and line the error must point at.
+		wantErr string //{dir}/ is replaced by the catalogue directory.
+	}{
+		{
+			name: "unknown shape",
+			files: map[string]string{
+				"a.json": catalogueFile(cardJSON("Mark", 0.5, "null"), cardJSON("Blast", 0.5, "hexagon")),
+			},
+			wantAt:  "a.json:3",
+			wantErr: `unknown impact shape "hexagon"`,
+		},
+		{
+			name: "duplicate name across files",
+			files: map[string]string{
+				"a.json": catalogueFile(cardJSON("Mark", 0.5, "null")),
+				"b.json": catalogueFile(cardJSON("Other", 0.25, "null"), cardJSON("Mark", 0.25, "null")),
+			},
+			wantAt:  "b.json:3",
+			wantErr: `duplicate card name "Mark" (first defined at {dir}/a.json:2)`,
+		},
+		{
+			name: "rarities over 1.0",
+			files: map[string]string{
+				"a.json": catalogueFile(cardJSON("Mark", 0.5, "null"), cardJSON("Swap", 0.75, "null"), cardJSON("Ward", 0.25, "null")),
+			},
+			wantAt:  "a.json:3",
+			wantErr: "card rarities sum to 1.5, expected 1.0",
+		},
+		{
+			name: "rarities under 1.0",
+			files: map[string]string{
+				"a.json": catalogueFile(cardJSON("Mark", 0.5, "null")),
+				"b.json": catalogueFile(cardJSON("Swap", 0.25, "null")),
+			},
+			wantAt:  "b.json:2",
+			wantErr: "card rarities sum to 0.75, expected 1.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, _, err := ReadCatalogueDir(dir)
+			if err == nil {
+				t.Fatal("catalogue loaded, want an error")
+			}
+
+			if want := filepath.Join(dir, tt.wantAt) + ": "; !strings.HasPrefix(err.Error(), want) {
+				t.Errorf("error %q, want it to start with %q", err, want)
+			}
+			if want := strings.ReplaceAll(tt.wantErr, "{dir}/", dir+string(filepath.Separator)); !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q, want it to contain %q", err, want)
+			}
+		})
+	}
+}
+
+func TestReadCatalogueDirValid(t *testing.T) {
+
+	dir := t.TempDir()
+	data := catalogueFile(cardJSON("Mark", 0.75, "null"), cardJSON("Blast", 0.25, "cross"))
+	if err := os.WriteFile(filepath.Join(dir, "a.json"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, cardSet, err := ReadCatalogueDir(dir)
+	if err != nil {
+		t.Fatalf("loading catalogue: %v", err)
+	}
+	if len(cardSet) != 2 {
+		t.Errorf("loaded %d cards, want 2", len(cardSet))
+	}
+}
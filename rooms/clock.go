@@ -0,0 +1,148 @@
+package rooms
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TurnClock sets the time players have to take their turns. The zero value disables the clock.
+type TurnClock struct {
+	TurnSeconds int `json:"turn_seconds"` //Seconds each turn lasts before the player is passed, 0 only uses the time bank.
+	MaxTimeouts int `json:"max_timeouts"` //Consecutive timeouts before the player forfeits, 0 never forfeits.
+	BankSeconds int `json:"bank_seconds"` //Chess style total time each player can use past TurnSeconds, 0 disables banks.
+}
+
+var ClockPresets = map[string]TurnClock{ //Named clocks rooms can be created with.
+	"none":     {},                                                  //No time limit.
+	"blitz":    {TurnSeconds: 15, MaxTimeouts: 2},                   //15 seconds a turn.
+	"standard": {TurnSeconds: 30, MaxTimeouts: 3},                   //30 seconds a turn.
+	"bank":     {TurnSeconds: 10, MaxTimeouts: 3, BankSeconds: 120}, //10 seconds a turn plus 2 minutes to spread over the game.
+	"chess":    {BankSeconds: 300},                                  //5 minutes for the whole game, running out loses.
+}
+
+// TurnTimeoutEvent is recorded when a player's turn clock runs out.
+type TurnTimeoutEvent struct {
+	PlayerID uuid.UUID `json:"player_id"`
+}
+
+func ClockFromName(name string) TurnClock { //Returns the clock with the given name, falling back to no clock.
+	return ClockPresets[name]
+}
+
+func (tc TurnClock) Enabled() bool { //Returns true if turns are timed.
+	return tc.TurnSeconds > 0 || tc.BankSeconds > 0
+}
+
+func (room *Room) ActivePlayer() *Player { //Returns the player whose turn it is, or nil. Room mutex must be held.
+
+	for _, pl := range room.Players {
+		if pl.Turn {
+			return pl
+		}
+	}
+
+	return nil
+}
+
+func (room *Room) startClock() { //Starts the active player's turn clock. Room mutex must be held.
+
+	room.turnSeq++ //Any timer from an earlier turn is now stale.
+	room.TurnDeadline = time.Time{}
+
+	active := room.ActivePlayer()
+	if !room.Clock.Enabled() || active == nil {
+		return
+	}
+
+	room.turnStarted = time.Now()
+	room.TurnDeadline = room.turnStarted.Add(time.Duration(room.Clock.TurnSeconds)*time.Second + active.BankLeft)
+
+	if room.noTimers { //Replays re-run recorded timeouts instead.
+		return
+	}
+
+	seq := room.turnSeq
+	room.turnTimer = time.AfterFunc(time.Until(room.TurnDeadline), func() {
+		room.Mu.Lock()
+		defer room.Mu.Unlock()
+
+		if seq != room.turnSeq || room.State != StateInProgress { //Turn already ended.
+			return
+		}
+
+		room.TurnTimedOut(room.ActivePlayer())
+	})
+}
+
+func (room *Room) stopClock() { //Stops the turn clock and takes any time used past the turn's seconds from the active player's bank. Room mutex must be held.
+
+	if room.turnTimer != nil {
+		room.turnTimer.Stop()
+		room.turnTimer = nil
+	}
+
+	room.turnSeq++
+
+	active := room.ActivePlayer()
+	if active == nil || room.Clock.BankSeconds == 0 || room.turnStarted.IsZero() {
+		return
+	}
+
+	over := time.Since(room.turnStarted) - time.Duration(room.Clock.TurnSeconds)*time.Second
+	if over > 0 {
+		active.BankLeft = max(active.BankLeft-over, 0)
+	}
+
+	room.turnStarted = time.Time{}
+}
+
+func (room *Room) TurnTimedOut(player *Player) { //Passes the player's turn, or forfeits the game once they time out too often. Room mutex must be held.
+
+	if player == nil {
+		return
+	}
+
+	fmt.Println("Turn timed out for player", player.ID)
+
+	room.Record(EventTurnTimeout, TurnTimeoutEvent{PlayerID: player.ID})
+
+	player.Timeouts++
+
+	flagged := room.Clock.TurnSeconds == 0 //With only a bank, running out of time loses.
+	if flagged || (room.Clock.MaxTimeouts > 0 && player.Timeouts >= room.Clock.MaxTimeouts) {
+		room.stopClock()
+		room.Forfeit(player)
+		return
+	}
+
+	room.EndTurn() //Auto pass.
+
+	room.CheckGameOver()
+}
+
+func (room *Room) Forfeit(loser *Player) { //Ends the game with the other player winning. Room mutex must be held.
+	room.Lose(loser, ReasonForfeit)
+}
+
+func (room *Room) Lose(loser *Player, reason string) { //Ends the game with the other player winning for the reason. Room mutex must be held.
+
+	for _, pl := range room.Players {
+		if pl.ID != loser.ID {
+			room.EndGame(&GameResult{WinnerID: pl.ID, WinnerFaction: pl.Faction, Reason: reason})
+			return
+		}
+	}
+
+	room.EndGame(&GameResult{WinnerID: uuid.Nil, Reason: reason}) //No one left to win.
+}
+
+func (room *Room) deadlineMillis() int64 { //Returns the turn deadline as unix milliseconds, 0 if turns are not timed. Room mutex must be held.
+
+	if room.TurnDeadline.IsZero() {
+		return 0
+	}
+
+	return room.TurnDeadline.UnixMilli()
+}
@@ -2,6 +2,7 @@ package rooms
 
 import (
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -18,33 +19,23 @@ var plRoomMapMu sync.RWMutex              //Read-Write Mutex allows multiple rea
 
 const roomCleanerFreq int = 10 //in minutes.
 
-func CreateRoomController() RoomController {
+func CreateRoomController() *RoomController {
 
 	rooms := []*Room{} //creating room list.
 
-	rc := RoomController{ //Creating room controller instance.
+	rc := &RoomController{ //Creating room controller instance.
 		Rooms: rooms,
 	}
 
 	return rc
 }
 
-func (rm *RoomController) CreateRoom() *Room { //Creating the room and gameboard.
+func (rm *RoomController) CreateRoom(opts RoomOptions) *Room { //Creating the room and gameboard.
 
 	rm.Mu.Lock()         //Locking the thread
 	defer rm.Mu.Unlock() //Defering unlock until after new room.
 
-	roomid := uuid.New()   //Creating the room id.
-	state := "Not Started" // Setting state.
-	pop := 0               //Setting population to 0
-
-	lastActive := time.Now()
-
-	gameboard := CreateBoard() //Creating gameboard.
-
-	players := []*Player{}
-
-	crRoom := &Room{ID: roomid, State: state, Pop: pop, Full: false, Board: &gameboard, Players: players, LastActive: lastActive} //create room instance.
+	crRoom := NewRoom(opts, time.Now().UnixNano()) //create room instance, seeded from the clock.
 
 	fmt.Println("New Room Created.")
 
@@ -54,16 +45,24 @@ func (rm *RoomController) CreateRoom() *Room { //Creating the room and gameboard
 
 }
 
-func JoinRoom(rmControl *RoomController, player *Player) {
+func JoinRoom(rmControl *RoomController, player *Player, opts RoomOptions) { //Joins the first available room with matching options, or creates one.
+
+	opts.Turns = opts.Turns.withDefaults()
 
 	availableRooms := false
 
-	for i := 0; i < len(rmControl.Rooms); i++ {
-		room := rmControl.Rooms[i]
-		if !room.Full && room.State == "Not Started" {
-			if JoinSpecificRoom(room, player) {
-				return
-			}
+	rmControl.Mu.Lock()
+	rooms := slices.Clone(rmControl.Rooms) //Joining takes the room mutex, so the controller is not held while joining.
+	rmControl.Mu.Unlock()
+
+	for _, room := range rooms {
+
+		room.Mu.Lock()
+		open := !room.Full && !room.Private && room.RatedSeats == nil && room.State == StateWaiting && room.Options().Equal(opts) //Private and ranked rooms are never matched into.
+		room.Mu.Unlock()
+
+		if open && JoinSpecificRoom(room, player) {
+			return
 		}
 	}
 
@@ -73,7 +72,7 @@ func JoinRoom(rmControl *RoomController, player *Player) {
 
 		// rmControl.Rooms = append(rmControl.Rooms, &crRoom)
 
-		crRoom := rmControl.CreateRoom()
+		crRoom := rmControl.CreateRoom(opts)
 
 		JoinSpecificRoom(crRoom, player)
 	}
@@ -82,36 +81,16 @@ func JoinRoom(rmControl *RoomController, player *Player) {
 
 func JoinSpecificRoom(room *Room, player *Player) bool { //Add player to room.
 
-	room.Mu.Lock()
-
-	plRoomMapMu.Lock()
-
-	if room.Full { //If room is full then don't add.
-
+	joined, full := room.AddPlayer(player)
+	if !joined { //If room is full then don't add.
 		return false
 	}
 
-	room.Players = append(room.Players, player) //Add player to room.
-	room.State = "Not Started"
-	room.Pop += 1 //Increase room population.
-
+	plRoomMapMu.Lock()
 	plRoomMap[player.ID] = room //inserting player id and room id into map.
-
-	fmt.Println("Player joined room:", room)
-
-	if room.Pop == 2 { //If room has two players already, change status to full.
-		room.Full = true
-		room.State = "Starting Room"
-
-		//We can start the game here as the room is now full.
-
-		room.SetPlayerFactions() //Set player factions to show sprites.
-
-	}
-	room.Mu.Unlock()
 	plRoomMapMu.Unlock()
 
-	if room.Full { //If Full, start game using goroutine.
+	if full { //If Full, start game using goroutine.
 		go func() {
 
 			time.Sleep(1 * time.Second)
@@ -136,9 +115,17 @@ func FindRoomByPlayer(player *Player) *Room {
 
 }
 
-func ManagePlayerMessage(player *Player, pMsg *PlayerMessage) { //Manages player actions/messages.
+func ManagePlayerMessage(rc *RoomController, player *Player, pMsg *PlayerMessage) { //Manages player actions/messages.
+
+	if rc.ManageLobbyAction(player, pMsg) { //Moving between rooms.
+		return
+	}
 
 	plRoom := FindRoomByPlayer(player) //Finding player room.
+	if plRoom == nil {                 //Queued players are not in a room yet.
+		SendErrorToPlayer(player, "not in a room")
+		return
+	}
 
 	plRoom.ManagePlActionInRm(player, pMsg)
 
@@ -156,9 +143,9 @@ func (rc *RoomController) StartRoomCleaner() { //Function used to remove inactiv
 
 			rc.Mu.Lock() //Locking mutex.
 
-			for _, room := range rc.Rooms {
+			for _, room := range slices.Clone(rc.Rooms) { //Iterating a copy as rooms are removed.
 
-				if time.Since(room.LastActive) >= time.Duration(roomCleanerFreq)*time.Minute { //Checks if room has been inactive for more than roomCleanerFreq minutes.
+				if room.Over() || time.Since(room.LastActive) >= time.Duration(roomCleanerFreq)*time.Minute { //Checks if the room's game is over or it has been inactive for more than roomCleanerFreq minutes.
 
 					rc.RemoveRoom(room) // Clean up or remove the room
 				}
@@ -171,6 +158,14 @@ func (rc *RoomController) StartRoomCleaner() { //Function used to remove inactiv
 
 }
 
+func (room *Room) Over() bool { //Returns true once the room is finished or abandoned and every player has left.
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	return room.Pop <= 0 && (room.State == StateFinished || room.State == StateAbandoned)
+}
+
 func (rc *RoomController) RemoveRoom(room *Room) { //Function to remove data from slice.
 
 	for i, rm := range rc.Rooms {
@@ -179,6 +174,8 @@ func (rc *RoomController) RemoveRoom(room *Room) { //Function to remove data fro
 
 			RemovePlayerRoomMapEntries(rm) //Removing from player room map.
 
+			ForgetRoomCode(rm) //Freeing the room's join code.
+
 			rc.Rooms = append(rc.Rooms[:i], rc.Rooms[i+1:]...) //Creates a new slice using everything before i (:i) and after i+1 (i+1)...
 			fmt.Println("Room Removed.")
 			break //Stop function after deletion.
@@ -217,13 +214,30 @@ func (room *Room) RemovePlayerFromRoom(player *Player) {
 
 	}
 
+	if len(nPlayers) == len(room.Players) { //Not seated here, nothing to remove.
+		room.Mu.Unlock()
+		return
+	}
+
 	room.Pop-- //Decrease room population.
 
+	room.Record(EventPlayerLeft, PlayerLeftEvent{PlayerID: player.ID})
+
 	fmt.Println("Player removed:", player)
 
 	room.Players = nPlayers //Update player list.
 
-	//Might need to check room state and start game end process if player count is <=1
+	switch {
+	case room.State == StateInProgress && room.Pop > 0: //Leaving mid game, after any reconnection grace, forfeits to the players still here.
+		room.Forfeit(player)
+	case room.Pop <= 0 && room.State != StateFinished: //No one left to play.
+		room.Transition(StateAbandoned)
+	case room.State == StateStarting && room.RatedSeats != nil: //Ranked rooms take no new players, the matchmaker re-queues whoever is left.
+		room.Transition(StateAbandoned)
+	case room.State == StateStarting: //Game cannot start without both players, reopen the room.
+		room.Full = false
+		room.Transition(StateWaiting)
+	}
 
 	room.Mu.Unlock() // Unlock Mutex
 
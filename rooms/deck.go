@@ -0,0 +1,168 @@
+package rooms
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// DeckList is the list of cards a deck is built from, card name to number of copies.
+type DeckList map[string]int
+
+// Deck is a player's draw and discard piles for a single game.
+type Deck struct {
+	DrawPile    []*Card    //Cards left to draw, the top of the pile is the end of the slice.
+	DiscardPile []*Card    //Cards that have been played.
+	EmptyRule   string     //What happens when drawing from an empty pile (reshuffle or fatigue).
+	Fatigue     int        //How many times the player tried to draw from an empty pile. The player loses at fatigueLimit.
+	Rand        *rand.Rand //The room's random source, used for shuffles.
+}
+
+const ( //Empty deck rules.
+	DeckReshuffle = "reshuffle" //The discard pile is shuffled back into the draw pile.
+	DeckFatigue   = "fatigue"   //No card is drawn and fatigue increases.
+)
+
+const fatigueLimit int = 3 //Empty draws under the fatigue rule before the player loses.
+
+func DeckRuleFromName(name string) string { //Returns the empty deck rule with the given name, falling back to reshuffling.
+
+	if name == DeckFatigue {
+		return DeckFatigue
+	}
+
+	return DeckReshuffle
+}
+
+const defaultDeckSize int = 20 //Number of cards in the default deck.
+const startHandSize int = 3    //Number of cards drawn at game start.
+
+func DefaultDeckList(cardSet []*Card) DeckList { //Builds a deck list from the catalogue, using rarity as the share of the deck.
+
+	list := DeckList{}
+	total := 0
+
+	for _, c := range cardSet {
+		copies := int(math.Round(c.Rarity * float64(defaultDeckSize)))
+		if copies > 0 {
+			list[c.Name] = copies
+			total += copies
+		}
+	}
+
+	if total == 0 && len(cardSet) > 0 { //Fall back to the first card so the deck is never empty.
+		list[cardSet[0].Name] = defaultDeckSize
+	}
+
+	return list
+}
+
+func BuildDeck(list DeckList, cardSet []*Card, emptyRule string, rng *rand.Rand) (*Deck, error) { //Creates a deck of card instances from the deck list, shuffled with rng.
+
+	deck := &Deck{DrawPile: []*Card{}, DiscardPile: []*Card{}, EmptyRule: emptyRule, Rand: rng}
+
+	names := make([]string, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Strings(names) //Build in a stable order so shuffles are the only source of randomness.
+
+	for _, name := range names {
+		card := FindCardByName(cardSet, name)
+		if card == nil {
+			return nil, fmt.Errorf("deck card %q is not in the catalogue", name)
+		}
+
+		for i := 0; i < list[name]; i++ {
+			deck.DrawPile = append(deck.DrawPile, card.NewInstance(rng))
+		}
+	}
+
+	deck.Shuffle()
+
+	return deck, nil
+}
+
+func (d *Deck) Shuffle() { //Shuffles the draw pile with the deck's random source.
+	d.Rand.Shuffle(len(d.DrawPile), func(i, j int) {
+		d.DrawPile[i], d.DrawPile[j] = d.DrawPile[j], d.DrawPile[i]
+	})
+}
+
+func (d *Deck) Draw() *Card { //Draws the top card, reshuffling or fatiguing when the draw pile is empty. Returns nil if no card was drawn.
+
+	if len(d.DrawPile) == 0 {
+		if d.EmptyRule == DeckReshuffle && len(d.DiscardPile) > 0 { //Shuffle the discard pile back in.
+			fmt.Println("Reshuffling discard pile.")
+			d.DrawPile = d.DiscardPile
+			d.DiscardPile = []*Card{}
+			d.Shuffle()
+		} else {
+			d.Fatigue++
+			fmt.Println("Deck empty, fatigue:", d.Fatigue)
+			return nil
+		}
+	}
+
+	top := d.DrawPile[len(d.DrawPile)-1]
+	d.DrawPile = d.DrawPile[:len(d.DrawPile)-1]
+
+	return top
+}
+
+func (d *Deck) Discard(card *Card) { //Adds a played card to the discard pile.
+	d.DiscardPile = append(d.DiscardPile, card)
+}
+
+func (d *Deck) CanDraw() bool { //Returns true if a draw would return a card.
+	return len(d.DrawPile) > 0 || (d.EmptyRule == DeckReshuffle && len(d.DiscardPile) > 0)
+}
+
+func (p *Player) DrawCards(n int) []*Card { //Draws up to n cards into the player's hand, returns the cards drawn.
+
+	drawn := []*Card{}
+
+	if p.Deck == nil {
+		return drawn
+	}
+
+	for i := 0; i < n; i++ {
+		card := p.Deck.Draw()
+		if card == nil {
+			break
+		}
+		p.Hand = append(p.Hand, card)
+		drawn = append(drawn, card)
+	}
+
+	return drawn
+}
+
+func (p *Player) RemoveFromHand(card *Card) bool { //Removes the card instance from the player's hand and discards it.
+
+	for i, c := range p.Hand {
+		if c == card {
+			p.Hand = append(p.Hand[:i], p.Hand[i+1:]...)
+
+			if p.Deck != nil {
+				p.Deck.Discard(card)
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func FindCardByName(cardSet []*Card, name string) *Card { //Returns the catalogue card with the name, or nil.
+
+	for _, c := range cardSet {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
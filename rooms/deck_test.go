@@ -0,0 +1,175 @@
+package rooms
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDefaultDeckUsesWholeCatalogue(t *testing.T) {
+
+	_, cardSet, err := ReadCatalogueDir("../cards")
+	if err != nil {
+		t.Fatalf("loading catalogue: %v", err)
+	}
+
+	list := DefaultDeckList(cardSet)
+
+	total := 0
+	for _, copies := range list {
+		total += copies
+	}
+	if total != defaultDeckSize {
+		t.Errorf("default deck has %d cards, want %d", total, defaultDeckSize)
+	}
+
+	for _, c := range cardSet {
+		if c.Rarity > 0 && list[c.Name] == 0 {
+			t.Errorf("%s has rarity %g but no copies in the default deck", c.Name, c.Rarity)
+		}
+	}
+
+	if errs := ValidateDeckList(list, cardSet, DefaultDeckRules); len(errs) > 0 {
+		t.Errorf("default deck breaks the deck rules: %v", errs)
+	}
+}
+
+func fatigueRoom(t *testing.T, rule string) (*Room, *Player, *Player) { //Returns a room in progress with two players whose draw piles are empty.
+
+	room := NewRoom(RoomOptions{Board: BoardPresets["classic"], Deck: rule}, 1)
+	a, b := newReplayPlayer(ownerA, "a"), newReplayPlayer(ownerB, "b")
+	JoinSpecificRoom(room, a)
+	JoinSpecificRoom(room, b)
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if err := room.Transition(StateInProgress); err != nil {
+		t.Fatalf("starting game: %v", err)
+	}
+
+	for _, pl := range room.Players {
+		pl.Deck = &Deck{DrawPile: []*Card{}, DiscardPile: []*Card{{Name: "Mark"}}, EmptyRule: room.DeckRule, Rand: room.Rand}
+		pl.Hand = []*Card{{Name: "Mark"}} //Cards left to play, so the game is not drawn.
+	}
+
+	return room, a, b
+}
+
+func TestFatigueLoses(t *testing.T) {
+
+	room, a, b := fatigueRoom(t, DeckFatigue)
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	for i := 1; i < fatigueLimit; i++ {
+		if drawn := a.DrawCards(1); len(drawn) != 0 {
+			t.Fatalf("drew %d cards from an empty deck", len(drawn))
+		}
+		if room.CheckGameOver() {
+			t.Fatalf("game ended after %d fatigue", i)
+		}
+	}
+
+	a.DrawCards(1)
+	if !room.CheckGameOver() {
+		t.Fatalf("game not over at fatigue %d", a.Deck.Fatigue)
+	}
+
+	if room.Result.Reason != ReasonFatigue || room.Result.WinnerID != b.ID {
+		t.Errorf("result = %+v, want %s won by fatigue", room.Result, b.ID)
+	}
+}
+
+func TestReshuffleNeverFatigues(t *testing.T) {
+
+	room, a, _ := fatigueRoom(t, "")
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if room.DeckRule != DeckReshuffle || room.Options().Deck != DeckReshuffle {
+		t.Fatalf("deck rule = %q, want %q by default", room.DeckRule, DeckReshuffle)
+	}
+
+	if drawn := a.DrawCards(1); len(drawn) != 1 || a.Deck.Fatigue != 0 {
+		t.Errorf("drew %d cards with fatigue %d, want the discard pile reshuffled", len(drawn), a.Deck.Fatigue)
+	}
+}
+
+func TestDeckCodeRoundTrip(t *testing.T) {
+
+	list := DeckList{"Mark": 5, "Double Mark": 2, "Swap": 1, "Ward": 1}
+
+	code, err := EncodeDeckCode(list)
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+
+	again, err := EncodeDeckCode(DeckList{"Ward": 1, "Swap": 1, "Mark": 5, "Double Mark": 2})
+	if err != nil || again != code {
+		t.Errorf("same deck gave code %q, want %q (err %v)", again, code, err)
+	}
+
+	got, err := DecodeDeckCode(code)
+	if err != nil {
+		t.Fatalf("decoding %q: %v", code, err)
+	}
+
+	if len(got) != len(list) {
+		t.Fatalf("decoded %v, want %v", got, list)
+	}
+	for name, copies := range list {
+		if got[name] != copies {
+			t.Errorf("%s: decoded %d copies, want %d", name, got[name], copies)
+		}
+	}
+}
+
+func TestDecodeMalformedDeckCode(t *testing.T) {
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"empty", ""},
+		{"not base64", "not a code!"},
+		{"unknown version", base64.RawURLEncoding.EncodeToString([]byte{2, 4, 'M', 'a', 'r', 'k', 5})},
+		{"zero length name", base64.RawURLEncoding.EncodeToString([]byte{deckCodeVersion, 0, 5})},
+		{"truncated name", base64.RawURLEncoding.EncodeToString([]byte{deckCodeVersion, 4, 'M', 'a'})},
+		{"missing count", base64.RawURLEncoding.EncodeToString([]byte{deckCodeVersion, 4, 'M', 'a', 'r', 'k'})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := DecodeDeckCode(tt.code)
+			if err == nil {
+				t.Fatalf("decoded %v, want an error", list)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid deck code") {
+				t.Errorf("error %q, want an invalid deck code error", err)
+			}
+		})
+	}
+}
+
+func TestDeckRulesCapCopies(t *testing.T) {
+
+	_, cardSet, err := ReadCatalogueDir("../cards")
+	if err != nil {
+		t.Fatalf("loading catalogue: %v", err)
+	}
+
+	list := DefaultDeckList(cardSet)
+	list["Mark"] += DefaultDeckRules.MaxCopies //Over the cap even for a common card.
+
+	errs := ValidateDeckList(list, cardSet, DefaultDeckRules)
+	found := false
+	for _, e := range errs {
+		found = found || strings.Contains(e.Error(), `card "Mark" has`)
+	}
+	if !found {
+		t.Errorf("deck with %d copies of Mark passed the copy cap: %v", list["Mark"], errs)
+	}
+}
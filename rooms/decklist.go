@@ -0,0 +1,292 @@
+package rooms
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeckRules are the constraints a player built deck list must meet.
+type DeckRules struct {
+	DeckSize   int         `json:"deck_size"`   //Exact number of cards in a deck.
+	MaxCopies  int         `json:"max_copies"`  //Max copies of any single card.
+	RarityCaps []RarityCap `json:"rarity_caps"` //Lower copy limits for rare cards.
+}
+
+// RarityCap limits the copies of cards with a rarity at or below MaxRarity.
+type RarityCap struct {
+	MaxRarity float64 `json:"max_rarity"`
+	MaxCopies int     `json:"max_copies"`
+}
+
+// SavedDeck is a deck list saved by a player.
+type SavedDeck struct {
+	ID      string    `json:"id"`      //Unique ID of the saved deck.
+	Owner   string    `json:"owner"`   //The player the deck belongs to.
+	Name    string    `json:"name"`    //Display name of the deck.
+	Cards   DeckList  `json:"cards"`   //Card name to number of copies.
+	Code    string    `json:"code"`    //Shareable deck code.
+	Created time.Time `json:"created"` //When the deck was saved.
+}
+
+// DeckStore holds saved decks and persists them to a JSON file.
+type DeckStore struct {
+	Path  string                //The file decks are saved to, empty keeps decks in memory only.
+	Decks map[string]*SavedDeck //Saved decks by ID.
+	Mu    sync.RWMutex
+}
+
+var DefaultDeckRules = DeckRules{ //The rules applied to every built deck.
+	DeckSize:  defaultDeckSize,
+	MaxCopies: 5, //Common cards, a quarter of the deck.
+	RarityCaps: []RarityCap{
+		{MaxRarity: 0.05, MaxCopies: 1}, //Very rare cards, one copy.
+		{MaxRarity: 0.2, MaxCopies: 2},  //Rare cards, two copies.
+	},
+}
+
+const deckCodeVersion byte = 1 //Version byte at the start of every deck code.
+
+var deckStore = &DeckStore{Decks: make(map[string]*SavedDeck)} //Global saved deck store.
+
+func LoadDeckStore(path string) error { //Loads saved decks from path and saves future changes there. A missing file starts an empty store.
+
+	deckStore.Mu.Lock()
+	defer deckStore.Mu.Unlock()
+
+	deckStore.Path = path
+	deckStore.Decks = make(map[string]*SavedDeck)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading deck store: %w", err)
+	}
+
+	decks := []*SavedDeck{}
+	if err := json.Unmarshal(data, &decks); err != nil {
+		return fmt.Errorf("parsing deck store %s: %w", path, err)
+	}
+
+	for _, d := range decks {
+		deckStore.Decks[d.ID] = d
+	}
+
+	fmt.Println("Loaded", len(decks), "saved decks.")
+
+	return nil
+}
+
+func (ds *DeckStore) save() error { //Writes all decks to the store file. Mutex must be held.
+
+	if ds.Path == "" {
+		return nil
+	}
+
+	decks := make([]*SavedDeck, 0, len(ds.Decks))
+	for _, d := range ds.Decks {
+		decks = append(decks, d)
+	}
+	sort.Slice(decks, func(i, j int) bool { return decks[i].ID < decks[j].ID })
+
+	data, err := json.MarshalIndent(decks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(ds.Path, data)
+}
+
+func SaveDeck(owner string, name string, list DeckList) (*SavedDeck, error) { //Validates and saves a deck list for the owner.
+
+	if owner == "" {
+		return nil, errors.New("deck owner is required")
+	}
+
+	_, cardSet := CurrentCatalogue()
+	if errs := ValidateDeckList(list, cardSet, DefaultDeckRules); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	code, err := EncodeDeckCode(list)
+	if err != nil {
+		return nil, err
+	}
+
+	deck := &SavedDeck{ID: uuid.NewString(), Owner: owner, Name: name, Cards: list, Code: code, Created: time.Now()}
+
+	deckStore.Mu.Lock()
+	defer deckStore.Mu.Unlock()
+
+	deckStore.Decks[deck.ID] = deck
+
+	if err := deckStore.save(); err != nil {
+		delete(deckStore.Decks, deck.ID)
+		return nil, fmt.Errorf("saving deck: %w", err)
+	}
+
+	return deck, nil
+}
+
+func ListDecks(owner string) []*SavedDeck { //Returns the owner's saved decks, oldest first.
+
+	deckStore.Mu.RLock()
+	defer deckStore.Mu.RUnlock()
+
+	decks := []*SavedDeck{}
+	for _, d := range deckStore.Decks {
+		if d.Owner == owner {
+			decks = append(decks, d)
+		}
+	}
+	sort.Slice(decks, func(i, j int) bool { return decks[i].Created.Before(decks[j].Created) })
+
+	return decks
+}
+
+func FindDeck(id string) *SavedDeck { //Returns the saved deck with the ID, or nil.
+
+	deckStore.Mu.RLock()
+	defer deckStore.Mu.RUnlock()
+
+	return deckStore.Decks[id]
+}
+
+func DeleteDeck(owner string, id string) error { //Deletes the owner's saved deck.
+
+	deckStore.Mu.Lock()
+	defer deckStore.Mu.Unlock()
+
+	deck, ok := deckStore.Decks[id]
+	if !ok || deck.Owner != owner { //Players can only delete their own decks.
+		return errors.New("deck not found")
+	}
+
+	delete(deckStore.Decks, id)
+
+	if err := deckStore.save(); err != nil {
+		deckStore.Decks[id] = deck
+		return fmt.Errorf("saving deck store: %w", err)
+	}
+
+	return nil
+}
+
+func ValidateDeckList(list DeckList, cardSet []*Card, rules DeckRules) []error { //Checks a deck list against the catalogue and deck rules, returning every problem found.
+
+	errs := []error{}
+	total := 0
+
+	names := make([]string, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Strings(names) //Report errors in a stable order.
+
+	for _, name := range names {
+		copies := list[name]
+
+		card := FindCardByName(cardSet, name)
+		if card == nil {
+			errs = append(errs, fmt.Errorf("card %q is not in the catalogue", name))
+			continue
+		}
+		if card.Type == "Null" {
+			errs = append(errs, fmt.Errorf("card %q cannot be added to decks", name))
+			continue
+		}
+		if copies <= 0 {
+			errs = append(errs, fmt.Errorf("card %q has %d copies", name, copies))
+			continue
+		}
+
+		if max := rules.MaxCopiesFor(card); copies > max {
+			errs = append(errs, fmt.Errorf("card %q has %d copies, max is %d", name, copies, max))
+		}
+
+		total += copies
+	}
+
+	if total != rules.DeckSize {
+		errs = append(errs, fmt.Errorf("deck has %d cards, must have %d", total, rules.DeckSize))
+	}
+
+	return errs
+}
+
+func (r DeckRules) MaxCopiesFor(card *Card) int { //Returns the max copies of the card allowed in a deck.
+
+	max := r.MaxCopies
+
+	for _, rc := range r.RarityCaps {
+		if card.Rarity <= rc.MaxRarity && rc.MaxCopies < max {
+			max = rc.MaxCopies
+		}
+	}
+
+	return max
+}
+
+func EncodeDeckCode(list DeckList) (string, error) { //Encodes a deck list as a short shareable code. Format: version byte, then per card a name length byte, the name and a count byte.
+
+	names := make([]string, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Strings(names) //The same deck always gives the same code.
+
+	buf := []byte{deckCodeVersion}
+
+	for _, name := range names {
+		copies := list[name]
+		if len(name) == 0 || len(name) > 255 {
+			return "", fmt.Errorf("card name %q cannot be encoded", name)
+		}
+		if copies <= 0 || copies > 255 {
+			return "", fmt.Errorf("card %q has %d copies, cannot be encoded", name, copies)
+		}
+
+		buf = append(buf, byte(len(name)))
+		buf = append(buf, name...)
+		buf = append(buf, byte(copies))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func DecodeDeckCode(code string) (DeckList, error) { //Decodes a deck code created by EncodeDeckCode.
+
+	buf, err := base64.RawURLEncoding.DecodeString(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deck code: %w", err)
+	}
+
+	if len(buf) == 0 || buf[0] != deckCodeVersion {
+		return nil, errors.New("invalid deck code: unknown version")
+	}
+
+	list := DeckList{}
+
+	for i := 1; i < len(buf); {
+		nameLen := int(buf[i])
+		if nameLen == 0 || i+1+nameLen+1 > len(buf) {
+			return nil, errors.New("invalid deck code: truncated")
+		}
+
+		name := string(buf[i+1 : i+1+nameLen])
+		list[name] += int(buf[i+1+nameLen])
+
+		i += 1 + nameLen + 1
+	}
+
+	return list, nil
+}
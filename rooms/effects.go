@@ -0,0 +1,114 @@
+package rooms
+
+import (
+	"fmt"
+)
+
+// EffectChange describes something that happened to a slot effect when effects ticked.
+type EffectChange struct {
+	SlotID     int    `json:"slot_id"`     //The slot the effect is on.
+	InstanceID string `json:"instance_id"` //The effect instance that changed.
+	Kind       string `json:"kind"`        //What happened (expired, detonated, damaged, destroyed, trigger, cancel)
+	Amount     int    `json:"amount,omitempty"`
+}
+
+const ( //Effect change kinds.
+	ChangeExpired   = "expired"   //The effect's duration ran out.
+	ChangeDetonated = "detonated" //The effect's fuse ran out and it exploded.
+	ChangeDamaged   = "damaged"   //The effect took damage over time.
+	ChangeDestroyed = "destroyed" //The effect's health reached 0.
+)
+
+func (b *Board) TickEffects() []EffectChange { //Counts down durations and fuses, deals damage over time and removes dead effects. Called at the end of each turn.
+
+	changes := []EffectChange{}
+	detonations := []SlotEffect{} //Fuses that ran out this tick.
+	destroyed := []SlotEffect{}
+
+	for _, sl := range b.Slots {
+		for _, eff := range sl.Effects {
+
+			if eff.DamagePerTurn > 0 { //Damage over time hits every other mark on the slot.
+				for _, other := range sl.Effects {
+					if other == eff || !other.IsDestroyable {
+						continue
+					}
+					other.TakeDamage(eff.DamagePerTurn, eff.DamageType)
+					changes = append(changes, EffectChange{SlotID: sl.ID, InstanceID: other.InstanceID.String(), Kind: ChangeDamaged, Amount: eff.DamagePerTurn})
+				}
+			}
+
+			if eff.FuseTurns > 0 { //Fuses detonate once they reach 0.
+				eff.FuseTurns--
+				if eff.FuseTurns == 0 {
+					detonations = append(detonations, SlotEffect{Slot: sl, Effect: eff})
+				}
+			}
+
+			if eff.Duration > 0 { //Timed effects expire once they reach 0.
+				eff.Duration--
+				if eff.Duration == 0 {
+					eff.Duration = -1 //Marks the expired effect for removal.
+					changes = append(changes, EffectChange{SlotID: sl.ID, InstanceID: eff.InstanceID.String(), Kind: ChangeExpired})
+				}
+			}
+		}
+	}
+
+	for _, det := range detonations { //Detonate after counting down so every fuse sees the same board.
+		fmt.Println("Effect detonated on slot", det.Slot.ID)
+
+		changes = append(changes, EffectChange{SlotID: det.Slot.ID, InstanceID: det.Effect.InstanceID.String(), Kind: ChangeDetonated, Amount: det.Effect.FuseDamage})
+
+		blast := &MarkEffect{Damage: det.Effect.FuseDamage, DamageType: det.Effect.DamageType}
+		destroyed = append(destroyed, b.ApplyDamageToSlotsFromCard(b.FuseSlots(det.Effect, det.Slot), blast)...)
+
+		det.Effect.FuseTurns = -1 //Marks the spent fuse for removal.
+	}
+
+	spentChanges, spentDestroyed := b.removeSpentEffects()
+	changes = append(changes, spentChanges...)
+	destroyed = append(destroyed, spentDestroyed...)
+
+	pipeline := b.NewTriggerPipeline() //Marks destroyed this tick can trigger.
+	pipeline.EmitDestroyed(destroyed, nil, 0)
+	pipeline.Run()
+
+	changes = append(changes, b.TakeChanges()...)
+
+	return changes
+}
+
+func (b *Board) FuseSlots(eff *MarkEffect, sl *Slot) []*Slot { //Returns the slots a fuse effect on sl hits when it detonates.
+
+	if eff.FuseShape == "" { //No shape, only the fuse's own slot.
+		return []*Slot{sl}
+	}
+
+	return b.GetAffectedSlots(eff.FuseShape, sl.ID, eff.Owner)
+}
+
+func (b *Board) removeSpentEffects() ([]EffectChange, []SlotEffect) { //Removes expired, detonated and destroyed effects, returning the destroyed ones.
+
+	changes := []EffectChange{}
+	destroyed := []SlotEffect{}
+
+	for _, sl := range b.Slots {
+		kept := []*MarkEffect{}
+
+		for _, eff := range sl.Effects {
+			switch {
+			case eff.FuseTurns < 0 || eff.Duration < 0: //Detonated or expired.
+			case eff.IsDestroyable && eff.Health <= 0:
+				changes = append(changes, EffectChange{SlotID: sl.ID, InstanceID: eff.InstanceID.String(), Kind: ChangeDestroyed})
+				destroyed = append(destroyed, SlotEffect{Slot: sl, Effect: eff})
+			default:
+				kept = append(kept, eff)
+			}
+		}
+
+		sl.Effects = kept
+	}
+
+	return changes, destroyed
+}
@@ -0,0 +1,366 @@
+package rooms
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const ( //Rating window settings.
+	matchWindowBase   = 100.0  //Largest rating gap accepted as soon as a player queues.
+	matchWindowGrowth = 20.0   //Rating gap added for every second waited.
+	matchWindowMax    = 1000.0 //Largest rating gap ever accepted.
+)
+
+var ErrNoName = errors.New("a player name is needed to play rated games")
+var ErrAlreadyQueued = errors.New("already in the matchmaking queue")
+var ErrNotQueued = errors.New("not in the matchmaking queue")
+var ErrNameInUse = errors.New("that name is already queued or playing a ranked game")
+
+// RatedSeat is a player in a ranked game and the name their rating is kept under.
+type RatedSeat struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+}
+
+// QueueEntry is a player waiting for a ranked match.
+type QueueEntry struct {
+	Player *Player
+	Name   string      //The name the player is rated under.
+	Rating float64     //The player's rating when they joined.
+	Opts   RoomOptions //Players are only matched with the same room options.
+	Joined time.Time
+
+	dropped bool //Set if the player disconnected while being handed to their room.
+}
+
+// Matchmaker pairs queued players with close ratings.
+type Matchmaker struct {
+	Queue   []*QueueEntry             //Queued players, longest waiting first.
+	handoff map[uuid.UUID]*QueueEntry //Matched players not yet in their room, by player ID.
+	Mu      sync.Mutex
+}
+
+var matchmaker = &Matchmaker{Queue: []*QueueEntry{}, handoff: map[uuid.UUID]*QueueEntry{}} //Global matchmaking queue.
+
+func (e *QueueEntry) Window(now time.Time) float64 { //Returns the largest rating gap the player accepts, widening the longer they wait.
+	return min(matchWindowBase+matchWindowGrowth*now.Sub(e.Joined).Seconds(), matchWindowMax)
+}
+
+func RatedName(player *Player) string { //Returns the name the player is rated under, empty for anonymous players.
+
+	if player.Name == "" || player.Name == "anon_player" {
+		return ""
+	}
+
+	return player.Name
+}
+
+func (rc *RoomController) CheckQueueable(player *Player) (string, error) { //Checks the player could join the ranked queue now. Returns the name they are rated under.
+
+	name := RatedName(player)
+	if name == "" {
+		return "", ErrNoName
+	}
+
+	if err := claimName(player, name); err != nil {
+		return "", err
+	}
+
+	if rc.ratedNameSeated(name) {
+		return "", ErrNameInUse
+	}
+
+	matchmaker.Mu.Lock()
+	defer matchmaker.Mu.Unlock()
+
+	if slices.ContainsFunc(matchmaker.Queue, func(e *QueueEntry) bool { return e.Player.ID == player.ID }) {
+		return "", ErrAlreadyQueued
+	}
+
+	if matchmaker.nameQueued(name) {
+		return "", ErrNameInUse
+	}
+
+	return name, nil
+}
+
+func EnqueuePlayer(rc *RoomController, player *Player, opts RoomOptions) error { //Adds a player who is not in a room to the ranked queue and tries to match them.
+
+	name, err := rc.CheckQueueable(player)
+	if err != nil {
+		return err
+	}
+
+	opts.Turns = opts.Turns.withDefaults()
+	rating := GetRating(name).Rating
+
+	matchmaker.Mu.Lock()
+
+	if slices.ContainsFunc(matchmaker.Queue, func(e *QueueEntry) bool { return e.Player.ID == player.ID }) { //Checked again, someone may have queued since.
+		matchmaker.Mu.Unlock()
+		return ErrAlreadyQueued
+	}
+
+	if matchmaker.nameQueued(name) {
+		matchmaker.Mu.Unlock()
+		return ErrNameInUse
+	}
+
+	matchmaker.Queue = append(matchmaker.Queue, &QueueEntry{Player: player, Name: name, Rating: rating, Opts: opts, Joined: time.Now()})
+
+	matchmaker.Mu.Unlock()
+
+	fmt.Println("Player queued:", name, rating)
+
+	SendMessageToPlayer(player, ConvertMsgToJson(&GameMessage{Type: "queued", Rating: rating}))
+
+	MatchQueue(rc) //Someone may already be waiting.
+
+	return nil
+}
+
+func claimName(player *Player, name string) error { //Checks the player holds the rating token for their name, sending them a new token if the name was unclaimed.
+
+	player.Mu.Lock()
+	token := player.RatingToken
+	player.Mu.Unlock()
+
+	issued, err := ClaimRatedName(name, token)
+	if err != nil || issued == "" {
+		return err
+	}
+
+	player.Mu.Lock()
+	player.RatingToken = issued
+	player.Mu.Unlock()
+
+	SendMessageToPlayer(player, ConvertMsgToJson(&GameMessage{Type: "rating_token", RatingToken: issued}))
+
+	return nil
+}
+
+func (m *Matchmaker) nameQueued(name string) bool { //Returns true if a player rated under the name is queued or being handed to a room. Mutex must be held.
+
+	for _, e := range m.Queue {
+		if e.Name == name {
+			return true
+		}
+	}
+
+	for _, e := range m.handoff {
+		if e.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rc *RoomController) ratedNameSeated(name string) bool { //Returns true if a player rated under the name has a seat in a ranked game that has not ended.
+
+	rc.Mu.Lock()
+	rooms := slices.Clone(rc.Rooms)
+	rc.Mu.Unlock()
+
+	for _, room := range rooms {
+
+		room.Mu.Lock()
+		ended := room.State == StateFinished || room.State == StateAbandoned
+		seated := !ended && slices.ContainsFunc(room.RatedSeats, func(s RatedSeat) bool { return s.Name == name })
+		room.Mu.Unlock()
+
+		if seated {
+			return true
+		}
+	}
+
+	return false
+}
+
+func LeaveQueue(player *Player) *QueueEntry { //Removes the player from the ranked queue. Returns their entry, or nil if they were not queued.
+
+	matchmaker.Mu.Lock()
+	defer matchmaker.Mu.Unlock()
+
+	i := slices.IndexFunc(matchmaker.Queue, func(e *QueueEntry) bool { return e.Player.ID == player.ID })
+	if i < 0 {
+		return nil
+	}
+
+	entry := matchmaker.Queue[i]
+	matchmaker.Queue = slices.Delete(matchmaker.Queue, i, i+1)
+
+	return entry
+}
+
+func MatchQueue(rc *RoomController) { //Pairs queued players whose ratings are within both of their windows and starts their games.
+
+	now := time.Now()
+
+	matchmaker.Mu.Lock()
+
+	pairs := [][2]*QueueEntry{}
+	matched := map[*QueueEntry]bool{}
+
+	for i, a := range matchmaker.Queue { //Longest waiting players pick first.
+		if matched[a] {
+			continue
+		}
+
+		var best *QueueEntry
+		bestGap := math.Inf(1)
+
+		for _, b := range matchmaker.Queue[i+1:] {
+			if matched[b] || b.Name == a.Name || !a.Opts.Equal(b.Opts) { //Players never play themselves.
+				continue
+			}
+
+			gap := math.Abs(a.Rating - b.Rating)
+			if gap <= min(a.Window(now), b.Window(now)) && gap < bestGap {
+				best, bestGap = b, gap
+			}
+		}
+
+		if best != nil {
+			matched[a], matched[best] = true, true
+			pairs = append(pairs, [2]*QueueEntry{a, best})
+		}
+	}
+
+	matchmaker.Queue = slices.DeleteFunc(matchmaker.Queue, func(e *QueueEntry) bool { return matched[e] })
+
+	for e := range matched { //Disconnects are held off until the players are in their room.
+		matchmaker.handoff[e.Player.ID] = e
+	}
+
+	matchmaker.Mu.Unlock()
+
+	for _, pair := range pairs {
+		rc.StartRankedRoom(pair[0], pair[1])
+	}
+}
+
+func HoldDisconnect(player *Player) bool { //Returns true if the player is being handed to a ranked room, which handles the disconnect once they are seated.
+
+	matchmaker.Mu.Lock()
+	defer matchmaker.Mu.Unlock()
+
+	entry := matchmaker.handoff[player.ID]
+	if entry == nil {
+		return false
+	}
+
+	entry.dropped = true
+
+	return true
+}
+
+func endHandoff(entries ...*QueueEntry) []*QueueEntry { //Ends the handoff of the entries. Returns the ones whose player disconnected during it.
+
+	matchmaker.Mu.Lock()
+	defer matchmaker.Mu.Unlock()
+
+	dropped := []*QueueEntry{}
+
+	for _, e := range entries {
+		delete(matchmaker.handoff, e.Player.ID)
+		if e.dropped {
+			dropped = append(dropped, e)
+		}
+	}
+
+	return dropped
+}
+
+func requeue(entry *QueueEntry) { //Puts a matched player back in the queue, keeping their place and widened window.
+
+	matchmaker.Mu.Lock()
+	defer matchmaker.Mu.Unlock()
+
+	entry.dropped = false
+	matchmaker.Queue = append(matchmaker.Queue, entry)
+	slices.SortStableFunc(matchmaker.Queue, func(x, y *QueueEntry) int { return x.Joined.Compare(y.Joined) })
+}
+
+func (rc *RoomController) StartRankedRoom(a *QueueEntry, b *QueueEntry) { //Creates a ranked room for two matched players.
+
+	matchmaker.Mu.Lock()
+	aDropped, bDropped := a.dropped, b.dropped
+	matchmaker.Mu.Unlock()
+
+	if aDropped || bDropped { //A player left before the room was made, the other waits for a new match.
+		endHandoff(a, b)
+		for _, e := range []*QueueEntry{a, b} {
+			if e.dropped {
+				DisconnectPlayer(e.Player, e.Player.conn())
+			} else {
+				requeue(e)
+			}
+		}
+		return
+	}
+
+	room := NewRoom(a.Opts, time.Now().UnixNano()) //Seats are set before the room is listed so matchmaking never fills it.
+	room.RatedSeats = []RatedSeat{{PlayerID: a.Player.ID, Name: a.Name}, {PlayerID: b.Player.ID, Name: b.Name}}
+
+	rc.Mu.Lock()
+	rc.Rooms = append(rc.Rooms, room)
+	rc.Mu.Unlock()
+
+	fmt.Println("Ranked match:", a.Name, a.Rating, "vs", b.Name, b.Rating)
+
+	for _, e := range []*QueueEntry{a, b} {
+		SendMessageToPlayer(e.Player, ConvertMsgToJson(&GameMessage{Type: "match_found", Rating: e.Rating}))
+		JoinSpecificRoom(room, e.Player) //Starts the game once both have joined.
+	}
+
+	for _, e := range endHandoff(a, b) { //Disconnected while joining, handled now they have a seat.
+		DisconnectPlayer(e.Player, e.Player.conn())
+	}
+}
+
+func (rc *RoomController) requeueStranded(room *Room, from RoomState, to RoomState) { //State hook putting players left in a ranked room that never started back in the queue. Room mutex is held.
+
+	if from != StateStarting || to != StateAbandoned || room.RatedSeats == nil {
+		return
+	}
+
+	stranded := slices.Clone(room.Players)
+	opts := room.Options()
+
+	go func() { //Leaving and queueing take the room mutex.
+		for _, pl := range stranded {
+
+			leaveRoom(room, pl)
+
+			SendMessageToPlayer(pl, ConvertMsgToJson(&GameMessage{Type: "match_cancelled"}))
+
+			if err := EnqueuePlayer(rc, pl, opts); err != nil { //Cannot queue, play unrated instead.
+				fmt.Println("ERROR:", err)
+				JoinRoom(rc, pl, opts)
+			}
+		}
+	}()
+}
+
+func StartMatchmaker(rc *RoomController, freq time.Duration) { //Rates ranked games and retries matching queued players every freq as their windows widen.
+
+	OnRoomState(rateFinishedGame)
+	OnRoomState(rc.requeueStranded)
+
+	go func() {
+
+		ticker := time.NewTicker(freq)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			MatchQueue(rc)
+		}
+	}()
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -16,23 +17,55 @@ type Player struct {
 	Turn      bool            //Tracks if able to place
 	Faction   string          //Player's faction (i.e. naughts or crosses)
 	Hand      []*Card         //Tracks Cards in hand (used for validating actions)
+	Deck      *Deck           //The player's draw and discard piles for the current game.
+	DeckList  DeckList        //The deck list the player's deck is built from, nil uses the default deck.
+	Timeouts  int             //Consecutive turns the player let the clock run out.
+	BankLeft  time.Duration   //Time left in the player's time bank.
 	Conn      *websocket.Conn //The client's connection.
 	SendQueue chan string     //Queue for writing messages to client.
 	Mu        sync.Mutex      //Player connection mutex.
+
+	ResumeToken string //Token the client reconnects with, sent on join.
+	RatingToken string //Token proving the player owns their rated name, sent by the client or issued on first ranked queue.
+	Connected   bool   //If the player's connection is up. Messages are dropped while disconnected.
+	disconnects int    //Counts disconnects and resumes so stale grace timers are ignored.
+	closed      bool   //Set once the send queue and connection are closed.
 }
 
 type GameMessage struct { //Game message for communicating turns to players.
-	Type         string  `json:"type"`                      //Game message type (i.e. setup, turn etc)
-	AddCards     []*Card `json:"cards_to_add,omitempty"`    //Cards to add to hand.
-	RemoveCards  []*Card `json:"cards_to_remove,omitempty"` //Cards to remove from hand.
-	TargetSlotID *int    `json:"target_slot,omitempty"`     //The id of the target slot, used to convey target slots from enemy moves (i.e. placing a mark.)
-	BoardState   []*Slot `json:"board_state,omitempty"`     //Cards to add to hand.
+	Type          string         `json:"type"`                      //Game message type (i.e. setup, turn etc)
+	AddCards      []*Card        `json:"cards_to_add,omitempty"`    //Cards to add to hand.
+	RemoveCards   []*Card        `json:"cards_to_remove,omitempty"` //Cards to remove from hand.
+	TargetSlotID  *int           `json:"target_slot,omitempty"`     //The id of the target slot, used to convey target slots from enemy moves (i.e. placing a mark.)
+	Targets       []int          `json:"targets,omitempty"`         //Every target slot of a multi-target play, in order.
+	BoardState    []*Slot        `json:"board_state,omitempty"`     //Cards to add to hand.
+	Result        *GameResult    `json:"result,omitempty"`          //The result of the game, only sent with game_over.
+	Board         *BoardConfig   `json:"board,omitempty"`           //The board dimensions, sent with game_start.
+	Rules         *TurnRules     `json:"rules,omitempty"`           //The room's turn rules, sent with game_start.
+	State         string         `json:"state,omitempty"`           //The room's new state, sent with room_state.
+	Deadline      int64          `json:"deadline,omitempty"`        //When the current turn times out in unix milliseconds, sent with game_start and turn_start if turns are timed.
+	Error         string         `json:"error,omitempty"`           //Why an action was rejected, sent with error.
+	EffectChanges []EffectChange `json:"effect_changes,omitempty"`  //Effects that expired, detonated or took damage at the end of the turn.
+	PlayerID      string         `json:"player_id,omitempty"`       //The receiving player's ID, sent with game_start so the client can tell its own marks.
+	ResumeToken   string         `json:"resume_token,omitempty"`    //Token to reconnect with, sent with session and resume.
+	RatingToken   string         `json:"rating_token,omitempty"`    //Token owning the player's rated name, sent with rating_token when first issued.
+	Faction       string         `json:"faction,omitempty"`         //The receiving player's faction, sent with resume. For spectators, the faction whose turn it is.
+	YourTurn      *bool          `json:"your_turn,omitempty"`       //If it is the receiving player's turn, sent with resume.
+	BankLeft      int64          `json:"bank_left,omitempty"`       //Milliseconds left in the receiving player's time bank, sent with resume.
+	Rating        float64        `json:"rating,omitempty"`          //The receiving player's rating, sent with queued, match_found and rating.
+	RoomCode      string         `json:"room_code,omitempty"`       //The join code of a private room, sent with room_joined.
+	ReconnectBy   int64          `json:"reconnect_by,omitempty"`    //When a disconnected opponent's seat is given up in unix milliseconds, sent with opponent_disconnected.
 }
 
 type PlayerMessage struct { //Message struct for when players send messages.
-	Action       string `json:"action"`                //Used to figure out message type (i.e. Play card or send chat etc)
-	CardName     string `json:"card_name,omitempty"`   //Name of card used, if no card then omit.
-	TargetSlotID int    `json:"target_slot,omitempty"` //The id of the target slot.
+	Action       string    `json:"action"`                //Used to figure out message type (i.e. Play card or send chat etc)
+	CardName     string    `json:"card_name,omitempty"`   //Name of card used, if no card then omit.
+	CardID       uuid.UUID `json:"card_id,omitempty"`     //Instance ID of the card used, preferred over the name when sent.
+	TargetSlotID int       `json:"target_slot,omitempty"` //The id of the target slot.
+	Targets      []int     `json:"targets,omitempty"`     //Ordered target slots for cards with several steps, used instead of target_slot when sent.
+	DeckID       string    `json:"deck_id,omitempty"`     //ID of a saved deck, used with select_deck.
+	DeckCode     string    `json:"deck_code,omitempty"`   //Shareable deck code, used with select_deck when no deck_id is sent.
+	RoomCode     string    `json:"room_code,omitempty"`   //Join code of a private room, used with join_room.
 }
 
 var defPlayer *Player = nil //Pointing to a null player. This is used to init card effects.
@@ -58,24 +91,44 @@ func SendMessageToPlayer(player *Player, msg string) { //function to send a mess
 func (p *Player) StartWriter() { //Method to start writer queue.
 	fmt.Println("Start msg writer for", p.ID)
 	go func() { //Starts go routine that constantly runs for player until disconnect.
-		for msg := range p.SendQueue {
+		for msg := range p.SendQueue { //Runs until the player is closed, across reconnects.
 
 			p.Mu.Lock() //Lock mutex.
+
+			if !p.Connected { //Dropped while disconnected, the client gets a snapshot when it resumes.
+				p.Mu.Unlock()
+				continue
+			}
+
 			fmt.Println("Sent msg")
 			err := p.Conn.WriteMessage(websocket.TextMessage, []byte(msg)) //Writes message to player.
 			p.Mu.Unlock()                                                  //Unlock after sending.
 			if err != nil {
-				fmt.Println("Write error:", err)
-				break // exit if there's an error (e.g. client disconnects)
+				fmt.Println("Write error:", err) //The reader notices the drop and disconnects the player.
 			}
 
 		}
 	}()
 }
 
+func (p *Player) conn() *websocket.Conn { //Returns the player's current connection.
+
+	p.Mu.Lock()
+	defer p.Mu.Unlock()
+
+	return p.Conn
+}
+
 func (p *Player) Close() {
 	p.Mu.Lock()
 
+	if p.closed { //Already closed.
+		p.Mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.Connected = false
+
 	close(p.SendQueue)
 	p.Conn.Close()
 
@@ -84,8 +137,24 @@ func (p *Player) Close() {
 	fmt.Println("Closed player:", p.ID)
 }
 
+func SendErrorToPlayer(player *Player, reason string) { //Sends an error message with the reason to the player.
+
+	msg := GameMessage{ //Create game message to send to clients.
+		Type:  "error", //Setting type to error
+		Error: reason,
+	}
+
+	SendMessageToPlayer(player, ConvertMsgToJson(&msg))
+}
+
+func (p *Player) HasCardsLeft() bool { //Returns true if the player still has cards to play or draw.
+	return len(p.Hand) > 0 || (p.Deck != nil && p.Deck.CanDraw())
+}
+
 func (rm *Room) SetPlayerFactions() {
 
+	factions := FactionsEvent{Factions: map[string]string{}}
+
 	for i, pl := range rm.Players {
 
 		pl.Mu.Lock()
@@ -96,8 +165,12 @@ func (rm *Room) SetPlayerFactions() {
 			pl.Faction = "x"
 		}
 
+		factions.Factions[pl.ID.String()] = pl.Faction
+
 		pl.Mu.Unlock()
 
 	}
 
+	rm.Record(EventFactionsAssigned, factions)
+
 }
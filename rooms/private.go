@@ -0,0 +1,250 @@
+package rooms
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" //No 0, O, 1 or I so codes are easy to read out.
+const roomCodeLength = 6
+
+var roomCodes = make(map[string]*Room) //Used for retrieving private rooms by join code. Global.
+var roomCodesMu sync.RWMutex           //Read-Write Mutex allows multiple readers, one write.
+
+var ErrRoomNotFound = errors.New("no room with that code")
+var ErrRoomClosed = errors.New("room is full or its game has started")
+var ErrAlreadyInRoom = errors.New("already in that room")
+
+func NewRoomCode() string { //Returns a random join code.
+
+	buf := make([]byte, roomCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err) //crypto/rand never fails on supported platforms.
+	}
+
+	for i, b := range buf {
+		buf[i] = roomCodeAlphabet[int(b)%len(roomCodeAlphabet)] //Alphabet length divides 256, so every letter is equally likely.
+	}
+
+	return string(buf)
+}
+
+func NormalizeRoomCode(code string) string { //Returns the code as it is stored, so players can type it in any case.
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (rc *RoomController) CreatePrivateRoom(opts RoomOptions) *Room { //Creates a room that is only joined by its code.
+
+	room := NewRoom(opts, time.Now().UnixNano())
+	room.Private = true //Set before the room is listed so matchmaking never sees it public.
+
+	roomCodesMu.Lock()
+	code := NewRoomCode()
+	for roomCodes[code] != nil { //Codes are unique among open rooms.
+		code = NewRoomCode()
+	}
+	roomCodes[code] = room
+	room.Code = code
+	roomCodesMu.Unlock()
+
+	rc.Mu.Lock()
+	rc.Rooms = append(rc.Rooms, room)
+	rc.Mu.Unlock()
+
+	fmt.Println("Private room created with code:", code)
+
+	return room
+}
+
+func FindRoomByCode(code string) *Room { //Returns the private room with the code, or nil.
+
+	roomCodesMu.RLock()
+	defer roomCodesMu.RUnlock()
+
+	return roomCodes[NormalizeRoomCode(code)]
+}
+
+func ForgetRoomCode(room *Room) { //Frees the room's join code.
+
+	if room.Code == "" {
+		return
+	}
+
+	roomCodesMu.Lock()
+	delete(roomCodes, room.Code)
+	roomCodesMu.Unlock()
+}
+
+func JoinRoomByCode(player *Player, code string) error { //Joins the private room with the code. Players already in a room use the join_room action instead.
+
+	room := FindRoomByCode(code)
+	if room == nil {
+		return ErrRoomNotFound
+	}
+
+	if FindRoomByPlayer(player) == room {
+		return ErrAlreadyInRoom
+	}
+
+	if !JoinSpecificRoom(room, player) {
+		return ErrRoomClosed
+	}
+
+	SendRoomJoined(player, room)
+
+	return nil
+}
+
+func SendRoomJoined(player *Player, room *Room) { //Tells the player the private room they are in and its code to share.
+
+	room.Mu.Lock()
+	msg := GameMessage{ //Create game message to send to clients.
+		Type:     "room_joined",
+		RoomCode: room.Code,
+		State:    string(room.State),
+	}
+	room.Mu.Unlock()
+
+	SendMessageToPlayer(player, ConvertMsgToJson(&msg))
+}
+
+func (rc *RoomController) ManageLobbyAction(player *Player, pMsg *PlayerMessage) bool { //Handles actions that move the player between rooms. Returns false for room actions.
+
+	var err error
+
+	switch pMsg.Action {
+	case "create_private_room":
+		err = rc.moveToPrivateRoom(player, pMsg)
+	case "join_room":
+		err = rc.moveToRoomByCode(player, pMsg)
+	case "join_queue":
+		err = rc.moveToQueue(player, pMsg)
+	case "leave_queue":
+		err = rc.leaveQueueForRoom(player)
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Println("ERROR:", err)
+		SendErrorToPlayer(player, err.Error())
+	}
+
+	return true
+}
+
+func (rc *RoomController) moveToPrivateRoom(player *Player, pMsg *PlayerMessage) error { //Leaves the player's room for a new private room with the same options.
+
+	current := FindRoomByPlayer(player)
+	if current == nil {
+		return ErrRoomNotFound
+	}
+
+	if err := leaveRoomFor(current, player, pMsg.Action); err != nil {
+		return err
+	}
+
+	room := rc.CreatePrivateRoom(current.Options())
+
+	JoinSpecificRoom(room, player)
+
+	SendRoomJoined(player, room)
+
+	return nil
+}
+
+func (rc *RoomController) moveToRoomByCode(player *Player, pMsg *PlayerMessage) error { //Leaves the player's room to join the private room with the code.
+
+	room := FindRoomByCode(pMsg.RoomCode)
+	if room == nil {
+		return ErrRoomNotFound
+	}
+
+	current := FindRoomByPlayer(player)
+	if current == room {
+		return ErrAlreadyInRoom
+	}
+
+	var fallback RoomOptions //Options to rematch with if the join fails.
+
+	if current != nil {
+		if err := leaveRoomFor(current, player, pMsg.Action); err != nil {
+			return err
+		}
+		fallback = current.Options()
+	} else if entry := LeaveQueue(player); entry != nil { //Leaving the ranked queue for the private room.
+		fallback = entry.Opts
+	} else {
+		return ErrRoomNotFound
+	}
+
+	if err := JoinRoomByCode(player, pMsg.RoomCode); err != nil { //Filled up or started since it was found, back to matchmaking.
+		JoinRoom(rc, player, fallback)
+		return err
+	}
+
+	return nil
+}
+
+func leaveRoomFor(room *Room, player *Player, action string) error { //Removes the player from the room if the action allows leaving in the room's state.
+
+	room.Mu.Lock()
+	err := room.CheckAction(action)
+	room.Mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	leaveRoom(room, player)
+
+	return nil
+}
+
+func leaveRoom(room *Room, player *Player) { //Removes the player from the room, leaving them between rooms until they join the next one.
+
+	room.RemovePlayerFromRoom(player)
+
+	plRoomMapMu.Lock()
+	delete(plRoomMap, player.ID)
+	plRoomMapMu.Unlock()
+}
+
+func (rc *RoomController) moveToQueue(player *Player, pMsg *PlayerMessage) error { //Leaves the player's room to queue for a ranked game with the same options.
+
+	current := FindRoomByPlayer(player)
+	if current == nil {
+		return ErrRoomNotFound
+	}
+
+	if _, err := rc.CheckQueueable(player); err != nil { //Checked before leaving so the player keeps their room.
+		return err
+	}
+
+	if err := leaveRoomFor(current, player, pMsg.Action); err != nil {
+		return err
+	}
+
+	if err := EnqueuePlayer(rc, player, current.Options()); err != nil { //Queued or seated elsewhere since the check, back to matchmaking.
+		JoinRoom(rc, player, current.Options())
+		return err
+	}
+
+	return nil
+}
+
+func (rc *RoomController) leaveQueueForRoom(player *Player) error { //Takes the player out of the ranked queue and back into public matchmaking.
+
+	entry := LeaveQueue(player)
+	if entry == nil {
+		return ErrNotQueued
+	}
+
+	JoinRoom(rc, player, entry.Opts)
+
+	return nil
+}
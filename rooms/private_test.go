@@ -0,0 +1,78 @@
+package rooms
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestJoinRoomSkipsPrivateRooms(t *testing.T) {
+
+	rc := CreateRoomController()
+	opts := RoomOptions{Board: BoardPresets["classic"]}
+
+	var wg sync.WaitGroup
+	private := make([]*Room, 20)
+	joined := make([]*Player, 20)
+
+	for i := range private { //Matchmaking runs while private rooms are created, as it does on the server.
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			private[i] = rc.CreatePrivateRoom(opts)
+		}()
+		go func() {
+			defer wg.Done()
+			joined[i] = newReplayPlayer(uuid.New(), "public")
+			JoinRoom(rc, joined[i], opts)
+		}()
+	}
+	wg.Wait()
+
+	for _, room := range private {
+		room.Mu.Lock()
+		pop := room.Pop
+		room.Mu.Unlock()
+
+		if pop != 0 {
+			t.Errorf("private room %s has %d players from matchmaking", room.Code, pop)
+		}
+		if FindRoomByCode(room.Code) != room {
+			t.Errorf("private room not found by its code %s", room.Code)
+		}
+	}
+
+	for _, pl := range joined {
+		if room := FindRoomByPlayer(pl); room == nil || room.Private {
+			t.Errorf("player %s was not matched into a public room", pl.ID)
+		}
+	}
+}
+
+func TestJoinQueueRejectedKeepsRoom(t *testing.T) {
+
+	rc := CreateRoomController()
+	opts := RoomOptions{Board: BoardPresets["classic"]}
+	name := "queue_" + uuid.NewString()
+
+	queued := newReplayPlayer(uuid.New(), name)
+	if err := EnqueuePlayer(rc, queued, opts); err != nil {
+		t.Fatalf("queueing: %v", err)
+	}
+	defer LeaveQueue(queued)
+
+	player := newReplayPlayer(uuid.New(), name)
+	player.RatingToken = queued.RatingToken //Same owner on a second connection.
+	JoinRoom(rc, player, opts)
+	room := FindRoomByPlayer(player)
+
+	rc.ManageLobbyAction(player, &PlayerMessage{Action: "join_queue"})
+
+	if got := FindRoomByPlayer(player); got != room {
+		t.Errorf("player moved from their room to %v after a rejected join_queue", got)
+	}
+	if LeaveQueue(player) != nil {
+		t.Errorf("player was queued under a name already in the queue")
+	}
+}
@@ -0,0 +1,319 @@
+package rooms
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const ( //Elo settings.
+	DefaultRating    = 1500.0 //Rating every new player starts with.
+	eloK             = 32.0   //Most a rating moves after one game.
+	provisionalK     = 64.0   //Faster moving K for a player's first games.
+	provisionalGames = 10     //Games played before a rating stops being provisional.
+	ratingHistoryMax = 100    //Rating changes kept per player.
+)
+
+// PlayerRating is a player's Elo rating and record.
+type PlayerRating struct {
+	Name    string         `json:"name"`    //The player name the rating is kept under.
+	Rating  float64        `json:"rating"`  //Current Elo rating.
+	Games   int            `json:"games"`   //Rated games played.
+	Wins    int            `json:"wins"`    //Rated games won.
+	Losses  int            `json:"losses"`  //Rated games lost.
+	Draws   int            `json:"draws"`   //Rated games drawn.
+	History []RatingChange `json:"history"` //Most recent rating changes, oldest first.
+}
+
+// storedRating is a rating as saved to the store file, with the hash of the token that owns its name.
+type storedRating struct {
+	*PlayerRating
+	TokenHash string `json:"token_hash,omitempty"`
+}
+
+// RatingChange records how one rated game moved a player's rating.
+type RatingChange struct {
+	RoomID   uuid.UUID `json:"room_id"`  //The room the game was played in, its replay has the same ID.
+	Opponent string    `json:"opponent"` //The opponent's name.
+	Score    float64   `json:"score"`    //1 for a win, 0.5 for a draw, 0 for a loss.
+	Before   float64   `json:"before"`   //Rating before the game.
+	After    float64   `json:"after"`    //Rating after the game.
+	Time     time.Time `json:"time"`     //When the game ended.
+}
+
+// RatingStore holds player ratings and persists them to a JSON file.
+type RatingStore struct {
+	Path    string                   //The file ratings are saved to, empty keeps ratings in memory only.
+	Ratings map[string]*PlayerRating //Ratings by player name.
+	Tokens  map[string]string        //Hashed rating token by player name. Only the holder of the token is rated under the name.
+	Mu      sync.RWMutex
+}
+
+var ratingStore = &RatingStore{Ratings: make(map[string]*PlayerRating), Tokens: make(map[string]string)} //Global rating store.
+
+var ErrNameClaimed = errors.New("that name is rated under another player, connect with its rating token")
+
+func LoadRatingStore(path string) error { //Loads ratings from path and saves future changes there. A missing file starts an empty store.
+
+	ratingStore.Mu.Lock()
+	defer ratingStore.Mu.Unlock()
+
+	ratingStore.Path = path
+	ratingStore.Ratings = make(map[string]*PlayerRating)
+	ratingStore.Tokens = make(map[string]string)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading rating store: %w", err)
+	}
+
+	ratings := []storedRating{}
+	if err := json.Unmarshal(data, &ratings); err != nil {
+		return fmt.Errorf("parsing rating store %s: %w", path, err)
+	}
+
+	for _, r := range ratings {
+		if r.PlayerRating == nil {
+			continue
+		}
+		if r.History == nil {
+			r.History = []RatingChange{}
+		}
+		if r.TokenHash != "" {
+			ratingStore.Tokens[r.Name] = r.TokenHash
+		}
+		ratingStore.Ratings[r.Name] = r.PlayerRating
+	}
+
+	fmt.Println("Loaded", len(ratings), "player ratings.")
+
+	return nil
+}
+
+func (rs *RatingStore) save() error { //Writes all ratings to the store file. Mutex must be held.
+
+	if rs.Path == "" {
+		return nil
+	}
+
+	ratings := make([]storedRating, 0, len(rs.Ratings))
+	for _, r := range rs.Ratings {
+		ratings = append(ratings, storedRating{PlayerRating: r, TokenHash: rs.Tokens[r.Name]})
+	}
+	sort.Slice(ratings, func(i, j int) bool { return ratings[i].Name < ratings[j].Name })
+
+	data, err := json.MarshalIndent(ratings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(rs.Path, data)
+}
+
+func hashRatingToken(token string) string { //Returns the hash a rating token is stored as.
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func ClaimRatedName(name string, token string) (string, error) { //Checks the token owns the name. An unclaimed name is claimed with a new token, which is returned and must be kept by the client.
+
+	ratingStore.Mu.Lock()
+	defer ratingStore.Mu.Unlock()
+
+	if hash, ok := ratingStore.Tokens[name]; ok {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(hashRatingToken(token))) != 1 {
+			return "", ErrNameClaimed
+		}
+		return "", nil
+	}
+
+	issued := rand.Text()
+
+	_, rated := ratingStore.Ratings[name]
+
+	ratingStore.Tokens[name] = hashRatingToken(issued)
+	ratingStore.get(name) //Saved with a rating so the claim is kept.
+
+	if err := ratingStore.save(); err != nil {
+		delete(ratingStore.Tokens, name)
+		if !rated {
+			delete(ratingStore.Ratings, name)
+		}
+		return "", err
+	}
+
+	return issued, nil
+}
+
+func (rs *RatingStore) get(name string) *PlayerRating { //Returns the player's rating, creating it if new. Mutex must be held.
+
+	r := rs.Ratings[name]
+	if r == nil {
+		r = &PlayerRating{Name: name, Rating: DefaultRating, History: []RatingChange{}}
+		rs.Ratings[name] = r
+	}
+
+	return r
+}
+
+func (r *PlayerRating) copy() *PlayerRating { //Returns a copy safe to use without the store mutex.
+
+	c := *r
+	c.History = append([]RatingChange{}, r.History...)
+
+	return &c
+}
+
+func GetRating(name string) *PlayerRating { //Returns a copy of the player's rating, the default rating if they have not played.
+
+	ratingStore.Mu.RLock()
+	defer ratingStore.Mu.RUnlock()
+
+	if r := ratingStore.Ratings[name]; r != nil {
+		return r.copy()
+	}
+
+	return &PlayerRating{Name: name, Rating: DefaultRating, History: []RatingChange{}}
+}
+
+func TopRatings(limit int) []*PlayerRating { //Returns up to limit ratings of players who have played, highest first, without their history.
+
+	ratingStore.Mu.RLock()
+	defer ratingStore.Mu.RUnlock()
+
+	top := make([]*PlayerRating, 0, len(ratingStore.Ratings))
+	for _, r := range ratingStore.Ratings {
+		if r.Games == 0 { //Claimed a name but not rated yet.
+			continue
+		}
+		c := *r
+		c.History = nil
+		top = append(top, &c)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Rating != top[j].Rating {
+			return top[i].Rating > top[j].Rating
+		}
+		return top[i].Name < top[j].Name
+	})
+
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+
+	return top
+}
+
+func ExpectedScore(rating float64, opponent float64) float64 { //Returns the Elo expected score of a player against the opponent.
+	return 1 / (1 + math.Pow(10, (opponent-rating)/400))
+}
+
+func (r *PlayerRating) kFactor() float64 { //Returns how far the player's rating moves per game.
+
+	if r.Games < provisionalGames {
+		return provisionalK
+	}
+
+	return eloK
+}
+
+func (r *PlayerRating) apply(roomID uuid.UUID, opponent string, opponentRating float64, score float64, at time.Time) { //Moves the rating after a game against the opponent.
+
+	before := r.Rating
+	r.Rating = before + r.kFactor()*(score-ExpectedScore(before, opponentRating))
+
+	r.Games++
+	switch score {
+	case 1:
+		r.Wins++
+	case 0:
+		r.Losses++
+	default:
+		r.Draws++
+	}
+
+	r.History = append(r.History, RatingChange{RoomID: roomID, Opponent: opponent, Score: score, Before: before, After: r.Rating, Time: at})
+	if len(r.History) > ratingHistoryMax { //Keeping the most recent changes.
+		r.History = r.History[len(r.History)-ratingHistoryMax:]
+	}
+}
+
+func RecordRatedGame(roomID uuid.UUID, name string, opponent string, score float64) (float64, float64) { //Updates both players' ratings from the first player's score. Returns the new ratings, SaveRatings writes them to disk.
+
+	ratingStore.Mu.Lock()
+	defer ratingStore.Mu.Unlock()
+
+	a := ratingStore.get(name)
+	b := ratingStore.get(opponent)
+	aBefore, bBefore := a.Rating, b.Rating //Both move from their ratings before the game.
+
+	now := time.Now()
+	a.apply(roomID, opponent, bBefore, score, now)
+	b.apply(roomID, name, aBefore, 1-score, now)
+
+	return a.Rating, b.Rating
+}
+
+func SaveRatings() error { //Writes the ratings to the store file.
+
+	ratingStore.Mu.Lock()
+	defer ratingStore.Mu.Unlock()
+
+	return ratingStore.save()
+}
+
+func rateFinishedGame(room *Room, from RoomState, to RoomState) { //State hook rating ranked games when they finish. Room mutex is held, so saving and telling players is left until it is released.
+
+	if to != StateFinished || len(room.RatedSeats) != 2 || room.Result == nil {
+		return
+	}
+
+	first, second := room.RatedSeats[0], room.RatedSeats[1]
+
+	score := 0.5 //Draw, or no winner.
+	switch room.Result.WinnerID {
+	case first.PlayerID:
+		score = 1
+	case second.PlayerID:
+		score = 0
+	}
+
+	firstRating, secondRating := RecordRatedGame(room.ID, first.Name, second.Name, score) //In memory only, so queueing again sees the new ratings.
+
+	fmt.Println("Rated game", room.ID, first.Name, firstRating, second.Name, secondRating)
+
+	newRatings := map[uuid.UUID]float64{first.PlayerID: firstRating, second.PlayerID: secondRating}
+
+	go func() {
+
+		if err := SaveRatings(); err != nil {
+			fmt.Println("Rating store error:", err)
+		}
+
+		room.Mu.Lock() //Held until EndGame has broadcast game_over.
+		players := slices.Clone(room.Players)
+		room.Mu.Unlock()
+
+		for _, pl := range players { //Players still in the room see their new rating.
+			if rating, ok := newRatings[pl.ID]; ok {
+				SendMessageToPlayer(pl, ConvertMsgToJson(&GameMessage{Type: "rating", Rating: rating}))
+			}
+		}
+	}()
+}
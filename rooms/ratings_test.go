@@ -0,0 +1,36 @@
+package rooms
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestClaimRatedName(t *testing.T) {
+
+	name := "claim_" + uuid.NewString() //The rating store is global, so every run claims a new name.
+
+	token, err := ClaimRatedName(name, "")
+	if err != nil || token == "" {
+		t.Fatalf("first claim = %q, %v, want a new token", token, err)
+	}
+
+	if _, err := ClaimRatedName(name, ""); !errors.Is(err, ErrNameClaimed) {
+		t.Errorf("claim without token: err = %v, want ErrNameClaimed", err)
+	}
+
+	if _, err := ClaimRatedName(name, "not the token"); !errors.Is(err, ErrNameClaimed) {
+		t.Errorf("claim with wrong token: err = %v, want ErrNameClaimed", err)
+	}
+
+	if issued, err := ClaimRatedName(name, token); err != nil || issued != "" {
+		t.Errorf("claim with token = %q, %v, want accepted without a new token", issued, err)
+	}
+
+	for _, r := range TopRatings(0) {
+		if r.Name == name {
+			t.Errorf("claimed name without games is on the leaderboard")
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package rooms
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// Replay files are JSON lines, one HistoryEntry per line in the order they happened.
+// The first entry is always room_created, which holds the seed needed to re-simulate the game.
+
+const ( //History event types.
+	EventRoomCreated      = "room_created"
+	EventPlayerJoined     = "player_joined"
+	EventPlayerLeft       = "player_left"
+	EventFactionsAssigned = "factions_assigned"
+	EventGameStarted      = "game_started"
+	EventCardsDrawn       = "cards_drawn"
+	EventAction           = "action"
+	EventBoardState       = "board_state"
+	EventEffectsTicked    = "effects_ticked"
+	EventGameOver         = "game_over"
+	EventTurnTimeout      = "turn_timeout"
+	EventStateChanged     = "state_changed"
+)
+
+const replayExt = ".jsonl" //Extension of replay files.
+
+var replayDir = "" //Directory finished games are saved to, empty disables saving.
+
+// RoomCreatedEvent is recorded when a room is created.
+type RoomCreatedEvent struct {
+	RoomID uuid.UUID   `json:"room_id"`
+	Seed   int64       `json:"seed"`
+	Board  BoardConfig `json:"board"`
+	Turns  TurnRules   `json:"turns"`
+	Clock  TurnClock   `json:"clock"`
+	Deck   string      `json:"deck_rule,omitempty"` //Empty deck rule, missing in older replays which reshuffle.
+}
+
+// PlayerJoinedEvent is recorded when a player joins a room.
+type PlayerJoinedEvent struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Name     string    `json:"name"`
+	Index    int       `json:"index"` //Position of the player in the room.
+}
+
+// PlayerLeftEvent is recorded when a player is removed from a room.
+type PlayerLeftEvent struct {
+	PlayerID uuid.UUID `json:"player_id"`
+}
+
+// FactionsEvent is recorded when factions are assigned, player ID to faction.
+type FactionsEvent struct {
+	Factions map[string]string `json:"factions"`
+}
+
+// GameStartedEvent is recorded when the game starts, before any card is drawn.
+type GameStartedEvent struct {
+	CatalogueVersion string     `json:"catalogue_version"`
+	DeckRule         string     `json:"deck_rule"`
+	DeckLists        []DeckList `json:"deck_lists"` //Deck list of each player by index, nil for the default deck.
+}
+
+// CardsDrawnEvent is recorded when a player draws cards.
+type CardsDrawnEvent struct {
+	PlayerID uuid.UUID `json:"player_id"`
+	Cards    []CardRef `json:"cards"`
+}
+
+// CardRef identifies a drawn card instance.
+type CardRef struct {
+	Name       string    `json:"name"`
+	InstanceID uuid.UUID `json:"instance_id"`
+}
+
+// ActionEvent is recorded for every player message, accepted or not.
+type ActionEvent struct {
+	PlayerID uuid.UUID     `json:"player_id"`
+	Message  PlayerMessage `json:"message"`
+	Accepted bool          `json:"accepted"`
+	Reason   string        `json:"reason,omitempty"` //Why the action was rejected.
+}
+
+// BoardStateEvent is recorded after an action has resolved.
+type BoardStateEvent struct {
+	Slots []*Slot `json:"slots"`
+}
+
+func SetReplayDir(dir string) error { //Sets the directory finished games are saved to, creating it if needed.
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating replay directory: %w", err)
+	}
+
+	replayDir = dir
+
+	return nil
+}
+
+func ReplayPath(roomID uuid.UUID) string { //Returns the path of the room's replay file.
+	return filepath.Join(replayDir, roomID.String()+replayExt)
+}
+
+func (room *Room) RecordDraw(player *Player, drawn []*Card) { //Records the cards a player drew. Room mutex must be held.
+
+	if len(drawn) == 0 {
+		return
+	}
+
+	event := CardsDrawnEvent{PlayerID: player.ID, Cards: []CardRef{}}
+	for _, c := range drawn {
+		event.Cards = append(event.Cards, CardRef{Name: c.Name, InstanceID: c.InstanceID})
+	}
+
+	room.Record(EventCardsDrawn, event)
+}
+
+func (room *Room) SaveReplay() { //Writes the room history to its replay file in the background. Room mutex must be held.
+
+	if replayDir == "" {
+		return
+	}
+
+	history := append([]HistoryEntry{}, room.History...) //Copy so the room can keep recording.
+	path := ReplayPath(room.ID)
+
+	go func() {
+		if err := WriteReplayFile(path, history); err != nil {
+			fmt.Println("Error saving replay:", err)
+			return
+		}
+		fmt.Println("Replay saved:", path)
+	}()
+}
+
+func WriteReplayFile(path string, history []HistoryEntry) error { //Writes history entries as JSON lines.
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf) //Encoder adds a newline after each entry.
+
+	for _, entry := range history {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+func writeFileAtomic(path string, data []byte) error { //Writes the file through a temporary file and rename, so readers and crashes never see it half written.
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+func ReadReplayFile(path string) ([]HistoryEntry, error) { //Reads a replay file written by WriteReplayFile.
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	history := []HistoryEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) //Board states can make long lines.
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		history = append(history, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func ReplayHistory(history []HistoryEntry) (*Room, error) { //Re-simulates a recorded game against the engine and checks every draw, action, board state and result matches.
+
+	if len(history) == 0 || history[0].Type != EventRoomCreated {
+		return nil, errors.New("replay must start with " + EventRoomCreated)
+	}
+
+	var created RoomCreatedEvent
+	if err := json.Unmarshal(history[0].Data, &created); err != nil {
+		return nil, fmt.Errorf("entry 1: %w", err)
+	}
+
+	room := NewRoom(RoomOptions{Board: created.Board, Turns: created.Turns, Clock: created.Clock, Deck: created.Deck}, created.Seed)
+	room.ID = created.RoomID
+	room.noTimers = true //Timeouts are replayed from the log.
+	players := make(map[uuid.UUID]*Player)
+
+	for i, entry := range history[1:] {
+		switch entry.Type {
+		case EventPlayerJoined:
+			var ev PlayerJoinedEvent
+			if err := json.Unmarshal(entry.Data, &ev); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+2, err)
+			}
+			if _, ok := players[ev.PlayerID]; !ok { //Players can rejoin after leaving.
+				players[ev.PlayerID] = newReplayPlayer(ev.PlayerID, ev.Name)
+			}
+			room.AddPlayer(players[ev.PlayerID])
+
+		case EventPlayerLeft:
+			var ev PlayerLeftEvent
+			if err := json.Unmarshal(entry.Data, &ev); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+2, err)
+			}
+			player, ok := players[ev.PlayerID]
+			if !ok {
+				return nil, fmt.Errorf("entry %d: unknown player %s", i+2, ev.PlayerID)
+			}
+			room.RemovePlayerFromRoom(player)
+
+		case EventGameStarted:
+			var ev GameStartedEvent
+			if err := json.Unmarshal(entry.Data, &ev); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+2, err)
+			}
+			if version, _ := CurrentCatalogue(); version != ev.CatalogueVersion {
+				return nil, fmt.Errorf("game used card catalogue %s, loaded catalogue is %s", ev.CatalogueVersion, version)
+			}
+			for idx, list := range ev.DeckLists { //Decks were picked before the game started.
+				if idx < len(room.Players) {
+					room.Players[idx].DeckList = list
+				}
+			}
+			room.DeckRule = ev.DeckRule
+			StartRoomGame(room)
+
+		case EventAction:
+			var ev ActionEvent
+			if err := json.Unmarshal(entry.Data, &ev); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+2, err)
+			}
+			if ev.Message.Action == "select_deck" { //Deck lists are restored from game_started.
+				continue
+			}
+			player, ok := players[ev.PlayerID]
+			if !ok {
+				return nil, fmt.Errorf("entry %d: unknown player %s", i+2, ev.PlayerID)
+			}
+			msg := ev.Message
+			room.ManagePlActionInRm(player, &msg)
+
+		case EventTurnTimeout:
+			var ev TurnTimeoutEvent
+			if err := json.Unmarshal(entry.Data, &ev); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+2, err)
+			}
+			player, ok := players[ev.PlayerID]
+			if !ok {
+				return nil, fmt.Errorf("entry %d: unknown player %s", i+2, ev.PlayerID)
+			}
+			room.Mu.Lock()
+			room.TurnTimedOut(player)
+			room.Mu.Unlock()
+		}
+	}
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	for _, pl := range players { //Stop the replay players' message drains.
+		close(pl.SendQueue)
+	}
+
+	if err := compareHistories(history, room.History); err != nil {
+		return room, err
+	}
+
+	return room, nil
+}
+
+func newReplayPlayer(id uuid.UUID, name string) *Player { //Creates a player without a connection whose messages are discarded.
+
+	player := &Player{
+		ID:        id,
+		Name:      name,
+		Faction:   "null",
+		Hand:      []*Card{},
+		SendQueue: make(chan string, 16),
+	}
+
+	go func() { //Nothing reads replay messages, drain them so sends never block.
+		for range player.SendQueue {
+		}
+	}()
+
+	return player
+}
+
+func compareHistories(recorded []HistoryEntry, replayed []HistoryEntry) error { //Checks the replayed game produced the same draws, actions, board states and result.
+
+	want := comparableEntries(recorded)
+	got := comparableEntries(replayed)
+
+	for i := 0; i < len(want) && i < len(got); i++ {
+		if want[i].Type != got[i].Type || !bytes.Equal(want[i].Data, got[i].Data) {
+			return fmt.Errorf("replay diverged at %s event %d:\nrecorded: %s\nreplayed: %s", want[i].Type, i+1, want[i].Data, got[i].Data)
+		}
+	}
+
+	if len(want) != len(got) {
+		return fmt.Errorf("replay has %d checked events, recorded game has %d", len(got), len(want))
+	}
+
+	return nil
+}
+
+func comparableEntries(history []HistoryEntry) []HistoryEntry { //Returns the entries produced by the engine, skipping deck selection which is not re-simulated.
+
+	entries := []HistoryEntry{}
+
+	for _, entry := range history {
+		switch entry.Type {
+		case EventCardsDrawn, EventBoardState, EventEffectsTicked, EventGameOver, EventTurnTimeout, EventStateChanged:
+			entries = append(entries, entry)
+		case EventAction:
+			var ev ActionEvent
+			if json.Unmarshal(entry.Data, &ev) == nil && ev.Message.Action == "select_deck" {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
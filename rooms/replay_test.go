@@ -0,0 +1,153 @@
+package rooms
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func seededGame(t *testing.T, seed int64) (*Room, *Player, *Player) { //Returns a started classic game between owner A and owner B.
+
+	if _, err := LoadCardsFromDir("../cards"); err != nil {
+		t.Fatalf("loading catalogue: %v", err)
+	}
+
+	room := NewRoom(RoomOptions{Board: BoardPresets["classic"]}, seed)
+	a, b := newReplayPlayer(ownerA, "a"), newReplayPlayer(ownerB, "b")
+	room.AddPlayer(a) //Added directly so the game starts now, not after the join delay.
+	room.AddPlayer(b)
+
+	StartRoomGame(room)
+
+	return room, a, b
+}
+
+func handIDs(player *Player) []uuid.UUID { //Returns the instance IDs of the player's hand, in order.
+	ids := []uuid.UUID{}
+	for _, c := range player.Hand {
+		ids = append(ids, c.InstanceID)
+	}
+	return ids
+}
+
+func handNames(player *Player) []string { //Returns the names of the player's hand, in order.
+	names := []string{}
+	for _, c := range player.Hand {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func lastActionAccepted(t *testing.T, room *Room) bool { //Returns if the room's last recorded action was accepted.
+
+	entry := room.History[len(room.History)-1]
+	for i := len(room.History) - 1; entry.Type != EventAction; i-- {
+		entry = room.History[i-1]
+	}
+
+	var ev ActionEvent
+	if err := json.Unmarshal(entry.Data, &ev); err != nil {
+		t.Fatalf("reading action: %v", err)
+	}
+
+	return ev.Accepted
+}
+
+func TestSameSeedSameGame(t *testing.T) {
+
+	first, a1, b1 := seededGame(t, 42)
+	second, a2, b2 := seededGame(t, 42)
+
+	if first.ID == second.ID {
+		t.Fatalf("rooms share an ID")
+	}
+
+	for _, pair := range [][2]*Player{{a1, a2}, {b1, b2}} {
+		if len(pair[0].Hand) != startHandSize {
+			t.Fatalf("hand has %d cards, want %d", len(pair[0].Hand), startHandSize)
+		}
+		if got, want := handNames(pair[1]), handNames(pair[0]); !slices.Equal(got, want) {
+			t.Errorf("hands = %v, want %v", got, want)
+		}
+		if got, want := handIDs(pair[1]), handIDs(pair[0]); !slices.Equal(got, want) {
+			t.Errorf("hand instance IDs = %v, want %v", got, want)
+		}
+	}
+
+	other, a3, _ := seededGame(t, 43)
+	if slices.Equal(handIDs(a3), handIDs(a1)) {
+		t.Errorf("room %s with another seed dealt the same instance IDs", other.ID)
+	}
+}
+
+func TestReplayHistoryRoundTrip(t *testing.T) {
+
+	room, a, b := seededGame(t, 7)
+
+	room.ManagePlActionInRm(a, &PlayerMessage{Action: "play_card", CardID: uuid.New(), TargetSlotID: 4}) //Not in hand, rejected.
+	if lastActionAccepted(t, room) {
+		t.Fatalf("card not in hand was accepted")
+	}
+
+	accepted := false
+	for _, c := range slices.Clone(a.Hand) { //Playing the first card the centre accepts, rejections are replayed too.
+		room.ManagePlActionInRm(a, &PlayerMessage{Action: "play_card", CardID: c.InstanceID, TargetSlotID: 4})
+		if accepted = lastActionAccepted(t, room); accepted {
+			break
+		}
+	}
+	if !accepted {
+		t.Fatalf("no card in hand %v could be played on the centre", handNames(a))
+	}
+
+	room.ManagePlActionInRm(b, &PlayerMessage{Action: "end_turn"})
+	room.ManagePlActionInRm(a, &PlayerMessage{Action: "end_turn"})
+
+	replayed, err := ReplayHistory(room.History)
+	if err != nil {
+		t.Fatalf("ReplayHistory: %v", err)
+	}
+
+	if replayed.ID != room.ID || replayed.Seed != room.Seed {
+		t.Errorf("replayed room %s seed %d, want %s seed %d", replayed.ID, replayed.Seed, room.ID, room.Seed)
+	}
+
+	for _, pl := range []*Player{a, b} {
+		var copy *Player
+		for _, rp := range replayed.Players {
+			if rp.ID == pl.ID {
+				copy = rp
+			}
+		}
+		if copy == nil {
+			t.Fatalf("player %s missing from the replay", pl.ID)
+		}
+		if got, want := handIDs(copy), handIDs(pl); !slices.Equal(got, want) {
+			t.Errorf("replayed hand instance IDs = %v, want %v", got, want)
+		}
+	}
+
+	if len(replayed.History) != len(room.History) {
+		t.Errorf("replay recorded %d entries, want %d", len(replayed.History), len(room.History))
+	}
+}
+
+func TestRemoveUnseatedPlayer(t *testing.T) { //Removing someone who is not seated changes nothing and records nothing.
+
+	room, _, _ := seededGame(t, 1)
+	entries := len(room.History)
+
+	room.RemovePlayerFromRoom(newReplayPlayer(uuid.New(), "stranger"))
+
+	if room.Pop != 2 || len(room.Players) != 2 {
+		t.Errorf("room has %d players and pop %d, want 2", len(room.Players), room.Pop)
+	}
+	if room.State != StateInProgress {
+		t.Errorf("room state %s, want %s", room.State, StateInProgress)
+	}
+	if len(room.History) != entries {
+		t.Errorf("recorded %v", room.History[entries:])
+	}
+}
@@ -1,7 +1,10 @@
 package rooms
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -10,23 +13,174 @@ import (
 
 type Room struct {
 	ID         uuid.UUID
-	State      string
+	State      RoomState //The stage of the game, only changed through Transition.
 	Pop        int
 	Full       bool
 	Board      *Board
 	Players    []*Player
 	LastActive time.Time
-	Mu         sync.Mutex
+	Result     *GameResult //The result of the game, nil until the game is over.
+	Private    bool        //Private rooms are only joined by code, never by matchmaking.
+	Code       string      //The join code of a private room.
+	RatedSeats []RatedSeat //The players of a ranked game and the names they are rated under, nil for unranked rooms.
+
+	Spectators  []*Spectator       //Connections watching the game, not counted in Pop.
+	specPending []spectatorMessage //Spectator messages waiting out the spectator delay.
+	specSeq     int                //Counts spectator messages so new spectators skip older ones.
+	specMu      sync.Mutex         //Guards the spectator fields, taken after the room mutex.
+
+	CatalogueVersion string  //The version of the card catalogue the game is played with.
+	Cards            []*Card //The card catalogue the game is played with, kept if the catalogue is reloaded mid-game.
+	DeckRule         string  //What happens when a player's draw pile is empty (reshuffle or fatigue).
+
+	Rules     TurnRules //How many cards players can play each turn.
+	PlaysMade int       //Card plays used this turn.
+
+	Clock        TurnClock   //How long players have for their turns.
+	TurnDeadline time.Time   //When the current turn times out, zero if turns are not timed.
+	turnStarted  time.Time   //When the current timed turn started.
+	turnTimer    *time.Timer //Fires when the current turn times out.
+	turnSeq      int         //Counts turn clocks so stale timers are ignored.
+	noTimers     bool        //Set on replays, which re-run recorded timeouts instead of timing turns.
+
+	Seed    int64          //The seed of the room's random source.
+	Rand    *rand.Rand     //The room's random source, used for every draw, shuffle and instance ID.
+	History []HistoryEntry //Record of what happened in the room.
+	Mu      sync.Mutex
+}
+
+// RoomOptions are the settings a room is created with. Players are only matched into rooms with the same options.
+type RoomOptions struct {
+	Board BoardConfig `json:"board"`
+	Turns TurnRules   `json:"turns"`
+	Clock TurnClock   `json:"clock"`
+	Deck  string      `json:"deck_rule"` //What happens when a draw pile is empty (reshuffle or fatigue).
+}
+
+func (o RoomOptions) Equal(other RoomOptions) bool { //Returns true if both options are the same.
+	return o.Board == other.Board && o.Turns.Equal(other.Turns) && o.Clock == other.Clock && DeckRuleFromName(o.Deck) == DeckRuleFromName(other.Deck)
+}
+
+type HistoryEntry struct { //A single recorded event in a room. Written one per line to replay files.
+	Time time.Time       `json:"time"`
+	Type string          `json:"type"`           //What happened (i.e. room_created)
+	Data json.RawMessage `json:"data,omitempty"` //Event details, one of the event structs in replay.go.
+}
+
+type GameResult struct { //Describes how a game ended.
+	WinnerID      uuid.UUID `json:"winner_id"`               //The ID of the winning player, uuid.Nil on a draw.
+	WinnerFaction string    `json:"winner_faction"`          //The winning player's faction (i.e. x or o)
+	WinningSlots  []int     `json:"winning_slots,omitempty"` //The IDs of the slots forming the winning line.
+	Reason        string    `json:"reason"`                  //Why the game ended (line, draw, forfeit)
+}
+
+const ( //Game over reasons.
+	ReasonLine    = "line"    //A player completed a line.
+	ReasonDraw    = "draw"    //The board is full, no player can play a card, or both players hold a line.
+	ReasonForfeit = "forfeit" //A player left or forfeited.
+	ReasonFatigue = "fatigue" //A player drew from their empty deck too often under the fatigue rule.
+)
+
+func NewRoom(opts RoomOptions, seed int64) *Room { //Creates a room with a board and a random source seeded with seed.
+
+	opts.Turns = opts.Turns.withDefaults()
+
+	gameboard := CreateBoard(opts.Board) //Creating gameboard.
+
+	room := &Room{
+		ID:         uuid.New(),   //Creating the room id.
+		State:      StateWaiting, // Setting state.
+		Pop:        0,            //Setting population to 0
+		Full:       false,
+		Board:      &gameboard,
+		Players:    []*Player{},
+		LastActive: time.Now(),
+		DeckRule:   DeckRuleFromName(opts.Deck),
+		Rules:      opts.Turns,
+		Clock:      opts.Clock,
+		Seed:       seed,
+		Rand:       rand.New(rand.NewSource(seed)),
+	}
+
+	room.Board.rng = room.Rand //Random shapes draw from the room's source.
+
+	room.Record(EventRoomCreated, RoomCreatedEvent{RoomID: room.ID, Seed: seed, Board: opts.Board, Turns: opts.Turns, Clock: opts.Clock, Deck: room.DeckRule}) //Seed is recorded so the game can be replayed.
+
+	return room
+}
+
+func (room *Room) Options() RoomOptions { //Returns the options the room was created with.
+	return RoomOptions{Board: room.Board.Config(), Turns: room.Rules, Clock: room.Clock, Deck: room.DeckRule}
+}
+
+func (room *Room) Record(eventType string, data any) { //Appends an entry to the room history. Room mutex must be held.
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		fmt.Println("Error recording event:", err)
+		return
+	}
+
+	room.History = append(room.History, HistoryEntry{Time: time.Now(), Type: eventType, Data: raw})
+}
+
+func (room *Room) AddPlayer(player *Player) (bool, bool) { //Adds the player to the room. Returns if the player joined and if the room is now full.
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if room.Full || room.State != StateWaiting { //If room is full or no longer open then don't add.
+		return false, false
+	}
+
+	room.Players = append(room.Players, player) //Add player to room.
+	room.Pop += 1                               //Increase room population.
+
+	room.Record(EventPlayerJoined, PlayerJoinedEvent{PlayerID: player.ID, Name: player.Name, Index: room.Pop - 1})
+
+	fmt.Println("Player joined room:", room.ID)
+
+	if room.Pop == 2 { //If room has two players already, change status to full.
+		room.Full = true
+
+		//We can start the game here as the room is now full.
+
+		room.SetPlayerFactions() //Set player factions to show sprites.
+
+		room.Transition(StateStarting)
+
+	}
+
+	return true, room.Full
 }
 
 func StartRoomGame(room *Room) {
 
 	room.Mu.Lock()
 
-	room.State = "In Progress" //Setting Game state to playing.
+	if err := room.Transition(StateInProgress); err != nil { //Players may have left while the room was starting.
+		fmt.Println("ERROR: Cannot start game:", err)
+		room.Mu.Unlock()
+		return
+	}
+
+	room.CatalogueVersion, room.Cards = CurrentCatalogue() //Fixing the catalogue for this game.
+
+	fmt.Println("Room using card catalogue version:", room.CatalogueVersion)
+
+	started := GameStartedEvent{CatalogueVersion: room.CatalogueVersion, DeckRule: room.DeckRule, DeckLists: []DeckList{}}
+	for i := 0; i < room.Pop; i++ {
+		started.DeckLists = append(started.DeckLists, room.Players[i].DeckList)
+	}
+	room.Record(EventGameStarted, started)
 
 	for i := 0; i < room.Pop; i++ { //Drawing Start Cards for players.
-		DrawStartCards(room.Players[i])
+		room.Players[i].BankLeft = time.Duration(room.Clock.BankSeconds) * time.Second
+		room.Players[i].Timeouts = 0
+
+		DrawStartCards(room.Players[i], room.Cards, room.DeckRule, room.Rand)
+
+		room.RecordDraw(room.Players[i], room.Players[i].Hand)
 
 		fmt.Println("The following player has cards: ", &room.Players[i])
 
@@ -34,16 +188,22 @@ func StartRoomGame(room *Room) {
 
 	room.Players[0].Turn = true //Allowing first player to have their turn.
 
-	//Start timer?
+	room.startClock() //Timing the first turn.
 
 	//Send message to players game has started and whose turn it is.
+	boardCfg := room.Board.Config() //Board dimensions for the client to draw.
+
 	for i := 0; i < room.Pop; i++ {
 
 		//msg := `{"type":"game_start"}`
 
 		msg := GameMessage{ //Create game message to send to clients.
-			Type:     "game_start",         //Setting type to game_start
-			AddCards: room.Players[i].Hand, //sending cards to add.
+			Type:     "game_start",          //Setting type to game_start
+			AddCards: room.Players[i].Hand,  //sending cards to add.
+			Board:    &boardCfg,             //sending board dimensions.
+			Rules:    &room.Rules,           //sending turn rules.
+			Deadline: room.deadlineMillis(), //sending when the first turn times out.
+			PlayerID: room.Players[i].ID.String(),
 		}
 
 		fmt.Println("Sending start message players:")
@@ -53,29 +213,117 @@ func StartRoomGame(room *Room) {
 
 	}
 
+	room.SendToSpectators(room.SpectatorSnapshot()) //Spectators that joined while the room was starting.
+
 	room.Mu.Unlock()
 
 }
 
 func (r *Room) ManagePlActionInRm(player *Player, pMsg *PlayerMessage) { //Manages player actions/messages and uses mutex for thread-safety.
 
-	r.Mu.Lock() //Locking the room mutex
+	r.Mu.Lock()         //Locking the room mutex
+	defer r.Mu.Unlock() //Unlock after func has completed.
 
 	fmt.Println("Managing Player action.")
 	r.LastActive = time.Now() //Update activity to show room is active.
 
+	err := r.resolveAction(player, pMsg)
+
+	event := ActionEvent{PlayerID: player.ID, Message: *pMsg, Accepted: err == nil}
+	if err != nil { //Rejected actions are recorded with the reason and sent back to the player.
+		event.Reason = err.Error()
+	}
+	r.Record(EventAction, event)
+
+	if err != nil { //Rejected actions do not use the turn.
+		SendErrorToPlayer(player, err.Error())
+		return
+	}
+
+	if r.State != StateInProgress { //Nothing to advance before the game starts or after it ends.
+		return
+	}
+
+	if pMsg.Action == "play_card" || pMsg.Action == "end_turn" { //Acting resets the player's timeouts.
+		player.Timeouts = 0
+	}
+
+	switch pMsg.Action {
+	case "play_card":
+		r.Record(EventBoardState, BoardStateEvent{Slots: r.Board.Slots}) //Board after the action resolved.
+
+		r.SendToSpectators(&GameMessage{Type: "game_state", BoardState: r.SendBoardState(nil)}) //Spectators see each play, players see the board when the turn ends.
+
+		if r.CheckGameOver() { //Check if the play ended the game.
+			return
+		}
+
+		if !r.TurnUsedUp() { //Player can keep playing.
+			return
+		}
+	case "end_turn":
+	default:
+		return
+	}
+
+	r.EndTurn() //End Turn after action.
+
+	r.CheckGameOver() //Check if effects or turn start ended the game.
+
+}
+
+func (r *Room) resolveAction(player *Player, pMsg *PlayerMessage) error { //Applies the action to the room. Returns why the action was rejected. Room mutex must be held.
+
+	if err := r.CheckAction(pMsg.Action); err != nil { //Actions are only accepted in some states.
+		fmt.Println("ERROR:", err)
+		return err
+	}
+
 	//Check message type and send to room if required.
 	switch action := pMsg.Action; action {
+	case "select_deck": //Decks are picked before the game starts.
+		return r.SelectDeck(player, pMsg)
 	case "play_card": //If user is playing a card.
 		fmt.Println("Managing Player action - switch case")
-		PlayCard(r, player, pMsg)
+		return PlayCard(r, player, pMsg)
+	case "end_turn": //If user is ending their turn early.
+		return r.EndPlayerTurn(player)
 
 	}
 
-	r.EndTurn() //End Turn after action.
+	return fmt.Errorf("unknown action %q", pMsg.Action)
+}
+
+func (room *Room) SelectDeck(player *Player, pMsg *PlayerMessage) error { //Sets the deck list the player's deck will be built from. Room mutex must be held.
+
+	var list DeckList
+
+	if pMsg.DeckID != "" { //Saved decks take priority over codes.
+		saved := FindDeck(pMsg.DeckID)
+		if saved == nil {
+			return errors.New("deck not found")
+		}
+		list = saved.Cards
+	} else {
+		decoded, err := DecodeDeckCode(pMsg.DeckCode)
+		if err != nil {
+			return err
+		}
+		list = decoded
+	}
+
+	_, cardSet := CurrentCatalogue()
+	if errs := ValidateDeckList(list, cardSet, DefaultDeckRules); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	player.DeckList = list
 
-	r.Mu.Unlock() //Unlock after func has completed.
+	fmt.Println("Player", player.ID, "selected deck.")
 
+	SendMessageToPlayer(player, ConvertMsgToJson(&GameMessage{Type: "select_deck_success"}))
+
+	return nil
 }
 
 func (room *Room) FlipTurns() { //Method that flips player turns in room.
@@ -87,16 +335,152 @@ func (room *Room) FlipTurns() { //Method that flips player turns in room.
 
 func (room *Room) EndTurn() { //Method to send game state to all players.
 
-	room.FlipTurns() //Flipping player turns after card has been played. Only allows 1 card per turn (might increase for balancing).
+	room.stopClock() //Charging the ending player's time bank.
+
+	room.FlipTurns() //Flipping player turns after the turn's cards have been played.
+	room.PlaysMade = 0
+
+	changes := room.Board.TakeChanges()                    //Triggers fired by the action.
+	changes = append(changes, room.Board.TickEffects()...) //Counting down timed effects and fuses.
+
+	if active := room.ActivePlayer(); active != nil { //Buffs count down on their owner's turns only.
+		room.Board.TickBuffs(active.ID)
+	}
+
+	room.StartTurn() //Active player draws for their turn.
+
+	changes = append(changes, room.Board.TakeChanges()...) //Turn start triggers.
+	if len(changes) > 0 {
+		room.Record(EventEffectsTicked, changes)
+	}
 
 	msg := GameMessage{ //Create game message to send to clients.
-		Type:       "game_state", //Setting type to game_start
-		BoardState: room.SendBoardState(),
+		Type:          "game_state", //Setting type to game_start
+		EffectChanges: changes,      //What changed when effects ticked or triggered.
 	}
 
 	for i := 0; i < room.Pop; i++ {
 
+		msg.BoardState = room.SendBoardState(room.Players[i]) //Each player sees their own hidden effects only.
+
+		SendMessageToPlayer(room.Players[i], ConvertMsgToJson(&msg)) //Add Message to send queue and convert to json compatible.
+
+	}
+
+	msg.BoardState = room.SendBoardState(nil)
+	room.SendToSpectators(&msg)
+}
+
+func (room *Room) StartTurn() { //Fires turn start triggers, draws a card for the active player and tells players whose turn it is. Room mutex must be held.
+
+	pipeline := room.Board.NewTriggerPipeline()
+	for i := 0; i < room.Pop; i++ {
+		if room.Players[i].Turn {
+			pipeline.Emit(TriggerEvent{Kind: TriggerOnTurnStart, Player: room.Players[i]})
+		}
+	}
+	pipeline.Run()
+
+	room.startClock() //Timing the new turn.
+
+	for i := 0; i < room.Pop; i++ {
+
+		msg := GameMessage{ //Create game message to send to clients.
+			Type:     "turn_start",          //Setting type to turn_start
+			Deadline: room.deadlineMillis(), //When the turn times out.
+		}
+
+		if room.Players[i].Turn { //Only the active player draws.
+			msg.AddCards = room.Players[i].DrawCards(1)
+			room.RecordDraw(room.Players[i], msg.AddCards)
+		}
+
 		SendMessageToPlayer(room.Players[i], ConvertMsgToJson(&msg)) //Add Message to send queue and convert to json compatible.
 
 	}
+
+	room.SendToSpectators(&GameMessage{Type: "turn_start", Deadline: room.deadlineMillis(), Faction: room.activeFaction()}) //Whose turn it is, without the draw.
+}
+
+func (room *Room) CheckGameOver() bool { //Checks the board for a win or draw and ends the game if found. Room mutex must be held.
+
+	winner, line, tied := room.CheckBoardWin(room.Board)
+	if winner != nil { //If a player has a line, they win.
+		room.EndGame(&GameResult{
+			WinnerID:      winner.ID,
+			WinnerFaction: winner.Faction,
+			WinningSlots:  line,
+			Reason:        ReasonLine,
+		})
+		return true
+	}
+
+	if tied { //Both players hold a line.
+		room.EndGame(&GameResult{
+			WinnerID: uuid.Nil,
+			Reason:   ReasonDraw,
+		})
+		return true
+	}
+
+	for _, pl := range room.Players { //Fatigued out under the fatigue rule.
+		if pl.Deck != nil && pl.Deck.Fatigue >= fatigueLimit {
+			room.Lose(pl, ReasonFatigue)
+			return true
+		}
+	}
+
+	handsEmpty := true
+	for i := 0; i < len(room.Players); i++ { //Checking if any player can still play.
+		if room.Players[i].HasCardsLeft() {
+			handsEmpty = false
+			break
+		}
+	}
+
+	if room.Board.IsFull() || handsEmpty { //If no more marks can be placed or played, it is a draw.
+		room.EndGame(&GameResult{
+			WinnerID: uuid.Nil,
+			Reason:   ReasonDraw,
+		})
+		return true
+	}
+
+	return false
+}
+
+func (room *Room) EndGame(result *GameResult) { //Ends the game and broadcasts the result to players. Room mutex must be held.
+
+	room.stopClock() //No more turns to time.
+
+	if !room.CanTransition(StateFinished) { //Game can only end once.
+		return
+	}
+
+	room.Result = result //Set first so state hooks see the result.
+
+	room.Transition(StateFinished)
+
+	for i := 0; i < len(room.Players); i++ { //No one can play after the game ends.
+		room.Players[i].Turn = false
+	}
+
+	fmt.Println("Game over:", result.Reason)
+
+	room.Record(EventGameOver, result)
+
+	room.SaveReplay() //Writing the finished game's log.
+
+	msg := GameMessage{ //Create game message to send to clients.
+		Type:   "game_over", //Setting type to game_over
+		Result: result,
+	}
+
+	for i := 0; i < len(room.Players); i++ {
+
+		SendMessageToPlayer(room.Players[i], ConvertMsgToJson(&msg)) //Add Message to send queue and convert to json compatible.
+
+	}
+
+	room.SendToSpectators(&msg)
 }
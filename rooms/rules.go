@@ -0,0 +1,227 @@
+package rooms
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/google/uuid"
+)
+
+const ( //Mark effect damage types.
+	DamagePlace  = "place"  //The effect is placed as a mark, blocked by blocking marks on the slot.
+	DamageNormal = "normal" //Damage that protective effects on the slot can block.
+	DamagePure   = "pure"   //Damage that cannot be blocked or affected by protective buffs.
+)
+
+var validDamageTypes = []string{"", DamagePlace, DamageNormal, DamagePure}
+
+var ErrSlotBlocked = errors.New("slot is blocked by a mark")            //A placement targeted a slot holding a blocking mark.
+var ErrNoAffectedSlots = errors.New("card does not affect any slot")    //The card's shape did not reach any slot.
+var ErrNoOwnMarks = errors.New("no marks of yours to buff")             //A buff reached none of the player's marks.
+var ErrNotStackable = errors.New("mark cannot be stacked on that slot") //A non-stackable placement targeted a slot that already holds effects.
+var ErrCardCancelled = errors.New("card was cancelled by a trigger")    //An on_target trigger cancelled the card, it is used up without effect.
+
+// CancelError is returned when an on_target trigger cancels a card, with the slots the card targeted. It matches ErrCardCancelled.
+type CancelError struct {
+	Targeted []*Slot
+}
+
+func (e *CancelError) Error() string {
+	return ErrCardCancelled.Error()
+}
+
+func (e *CancelError) Is(target error) bool {
+	return target == ErrCardCancelled
+}
+
+func (b *Board) CardSlots(card *Card, player *Player, tarSlotID int) []*Slot { //Returns the slots the card affects when the player plays it on the target slot.
+
+	switch card.ImpactType { //Determine which slots to effect using impact type.
+	case "singular": //This means a singular slot is effected.
+		if tSlot := b.ReturnSlotFromID(tarSlotID); tSlot != nil {
+			return []*Slot{tSlot}
+		}
+		return []*Slot{}
+	case "multiple": //Means multiple slots get affected.
+		return b.GetAffectedSlots(card.ImpactShape, tarSlotID, player.ID)
+	}
+
+	return []*Slot{}
+}
+
+func (b *Board) ValidateCardPlay(card *Card, player *Player, tarSlotID int) ([]*Slot, []*Slot, error) { //Checks the card can be played on the target. Returns the affected slots and the slots the effect can be added to.
+
+	affected := b.CardSlots(card, player, tarSlotID)
+	if len(affected) == 0 {
+		return nil, nil, ErrNoAffectedSlots
+	}
+
+	if card.MarkEffect == nil || card.MarkEffect.DamageType != DamagePlace { //Only placements can be blocked.
+		return affected, affected, nil
+	}
+
+	placeable := []*Slot{}
+	stacked := false //If a slot was only refused because the mark cannot stack.
+	for _, sl := range affected {
+		switch {
+		case sl.IsBlocked():
+		case !card.MarkEffect.IsStackable && !sl.IsEmpty(): //Non-stackable marks need a slot of their own.
+			stacked = true
+		default:
+			placeable = append(placeable, sl)
+		}
+	}
+
+	if len(placeable) == 0 { //Singular placements on a refused slot, or shapes where every slot is refused, are rejected.
+		if stacked {
+			return nil, nil, ErrNotStackable
+		}
+		return nil, nil, ErrSlotBlocked
+	}
+
+	return affected, placeable, nil
+}
+
+func (b *Board) ResolveCardPlay(card *Card, player *Player, tarSlotID int, rng *rand.Rand) error { //Validates then applies the card's damage and effect. Nothing changes if the play is rejected. Returns a CancelError if a trigger cancelled the card.
+
+	affected, placeable, err := b.ValidateCardPlay(card, player, tarSlotID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("slots to affect: ", len(affected))
+
+	if card.MarkEffect == nil { //Cards without an effect do nothing to the board.
+		return nil
+	}
+
+	pipeline := b.NewTriggerPipeline()
+
+	if pipeline.CheckTarget(player, affected) { //A ward cancelled the card, ResolveTargets undoes its other steps.
+		fmt.Println("Card cancelled by a trigger.")
+		return &CancelError{Targeted: affected}
+	}
+
+	destroyed := b.ApplyDamageToSlotsFromCard(affected, card.MarkEffect) //Damage first so new marks are not hit by their own card.
+	pipeline.EmitDestroyed(destroyed, player, 0)
+
+	for _, sl := range placeable { //Cycle through slots and add effect.
+		if placed := sl.AddEffectToSlot(card.MarkEffect, player, rng); placed != nil {
+			pipeline.Emit(TriggerEvent{Kind: TriggerOnPlace, Slot: sl, Effect: placed, Player: player})
+		}
+	}
+
+	pipeline.Run() //Reacting to the destroyed and placed marks.
+
+	return nil
+}
+
+func (sl *Slot) IsBlocked() bool { //Returns true if the slot holds a mark that prevents placements.
+
+	for _, eff := range sl.Effects {
+		if eff.IsBlocking {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (sl *Slot) IsProtected() bool { //Returns true if the slot holds a blocking mark that cannot be destroyed, which blocks non-pure damage.
+
+	for _, eff := range sl.Effects {
+		if eff.IsBlocking && !eff.IsDestroyable {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Board) ResolveBuffPlay(card *Card, player *Player, tarSlotID int) error { //Applies the buff card to the player's own marks in the affected slots.
+
+	affected := b.CardSlots(card, player, tarSlotID)
+	if len(affected) == 0 {
+		return ErrNoAffectedSlots
+	}
+
+	if card.MarkEffect == nil {
+		return nil
+	}
+
+	marks := []*MarkEffect{}
+	for _, sl := range affected {
+		for _, eff := range sl.Effects {
+			if eff.Owner == player.ID && eff.IsWinEffect { //Buffs only apply to the player's own marks.
+				marks = append(marks, eff)
+			}
+		}
+	}
+
+	if len(marks) == 0 { //Buff would be wasted, reject rather than use the card.
+		return ErrNoOwnMarks
+	}
+
+	if b.NewTriggerPipeline().CheckTarget(player, affected) { //A ward cancelled the card, ResolveTargets undoes its other steps.
+		fmt.Println("Card cancelled by a trigger.")
+		return &CancelError{Targeted: affected}
+	}
+
+	for _, mark := range marks {
+		mark.ApplyBuff(card.MarkEffect)
+	}
+
+	fmt.Println("Buffed", len(marks), "marks.")
+
+	return nil
+}
+
+func (m *MarkEffect) ApplyBuff(buff *MarkEffect) { //Adds the buff's heal, shield and fortify to the mark.
+
+	m.Health += buff.Heal
+
+	if buff.Shield > 0 {
+		m.ShieldPoints += buff.Shield
+		m.ShieldTurns = max(m.ShieldTurns, buff.BuffTurns)
+	}
+
+	m.FortifiedTurns = max(m.FortifiedTurns, buff.FortifyTurns)
+}
+
+func (m *MarkEffect) TakeDamage(damage int, damageType string) { //Reduces the mark's health. Shields and fortify only stop non-pure damage.
+
+	if damageType != DamagePure {
+		if m.FortifiedTurns > 0 { //Fortified marks cannot be destroyed.
+			return
+		}
+
+		absorbed := min(m.ShieldPoints, damage) //Shield takes the damage first.
+		m.ShieldPoints -= absorbed
+		damage -= absorbed
+	}
+
+	m.Health -= damage
+}
+
+func (b *Board) TickBuffs(owner uuid.UUID) { //Counts down shield and fortify turns on the owner's marks, called as the owner's turn starts so a buff lasting N turns covers N enemy turns.
+
+	for _, sl := range b.Slots {
+		for _, eff := range sl.Effects {
+			if eff.Owner != owner {
+				continue
+			}
+
+			if eff.FortifiedTurns > 0 {
+				eff.FortifiedTurns--
+			}
+
+			if eff.ShieldTurns > 0 {
+				eff.ShieldTurns--
+				if eff.ShieldTurns == 0 { //Timed shield expired.
+					eff.ShieldPoints = 0
+				}
+			}
+		}
+	}
+}
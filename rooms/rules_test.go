@@ -0,0 +1,265 @@
+package rooms
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func buffCard(shape string, buff MarkEffect) *Card { //Returns a buff card hitting the shape, singular for "null".
+
+	impact := "multiple"
+	if shape == "null" {
+		impact = "singular"
+	}
+
+	return &Card{Name: "Test Buff", Type: "buff", ImpactType: impact, ImpactShape: shape, MarkEffect: &buff}
+}
+
+func markOn(b *Board, slotID int) *MarkEffect { //Returns the first effect on the slot.
+	return b.ReturnSlotFromID(slotID).Effects[0]
+}
+
+func TestHealOnlyOwnWinMarks(t *testing.T) {
+
+	b := testBoard("classic")
+	placeMarks(b, ownerA, 4, 1)
+	placeMarks(b, ownerB, 3)
+	placeNonWin(b, ownerA, 5)
+
+	if err := b.ResolveBuffPlay(buffCard("radius", MarkEffect{Heal: 2}), &Player{ID: ownerA}, 4); err != nil {
+		t.Fatalf("ResolveBuffPlay: %v", err)
+	}
+
+	for _, tc := range []struct {
+		slot int
+		want int
+	}{
+		{slot: 4, want: 3}, //Own mark, targeted.
+		{slot: 1, want: 3}, //Own mark, in the radius.
+		{slot: 3, want: 1}, //Enemy mark.
+		{slot: 5, want: 1}, //Own effect that is not a mark.
+	} {
+		if got := markOn(b, tc.slot).Health; got != tc.want {
+			t.Errorf("slot %d health = %d, want %d", tc.slot, got, tc.want)
+		}
+	}
+}
+
+func TestShieldAbsorbsNormalNotPure(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		damageType string
+		damage     int
+		wantHealth int
+		wantShield int
+	}{
+		{name: "normal damage absorbed", damageType: DamageNormal, damage: 3, wantHealth: 1, wantShield: 2},
+		{name: "normal damage past the shield", damageType: DamageNormal, damage: 7, wantHealth: -1, wantShield: 0},
+		{name: "untyped damage absorbed", damageType: "", damage: 5, wantHealth: 1, wantShield: 0},
+		{name: "pure damage ignores the shield", damageType: DamagePure, damage: 1, wantHealth: 0, wantShield: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			mark := &MarkEffect{Owner: ownerA, Health: 1, IsWinEffect: true}
+			mark.ApplyBuff(&MarkEffect{Shield: 5})
+
+			mark.TakeDamage(tt.damage, tt.damageType)
+
+			if mark.Health != tt.wantHealth || mark.ShieldPoints != tt.wantShield {
+				t.Errorf("health, shield = %d, %d, want %d, %d", mark.Health, mark.ShieldPoints, tt.wantHealth, tt.wantShield)
+			}
+		})
+	}
+}
+
+func TestTimedShieldExpires(t *testing.T) {
+
+	b := testBoard("classic")
+	placeMarks(b, ownerA, 0)
+
+	if err := b.ResolveBuffPlay(buffCard("null", MarkEffect{Shield: 100, BuffTurns: 2}), &Player{ID: ownerA}, 0); err != nil {
+		t.Fatalf("ResolveBuffPlay: %v", err)
+	}
+
+	mark := markOn(b, 0)
+
+	b.TickBuffs(ownerB) //The opponent's turn starting does not count down the owner's buffs.
+	if mark.ShieldPoints != 100 || mark.ShieldTurns != 2 {
+		t.Fatalf("after the opponent's turn start: shield %d for %d turns, want 100 for 2", mark.ShieldPoints, mark.ShieldTurns)
+	}
+
+	b.TickBuffs(ownerA)
+	if mark.ShieldPoints != 100 || mark.ShieldTurns != 1 {
+		t.Fatalf("after 1 tick: shield %d for %d turns, want 100 for 1", mark.ShieldPoints, mark.ShieldTurns)
+	}
+
+	b.TickBuffs(ownerA)
+	if mark.ShieldPoints != 0 || mark.ShieldTurns != 0 {
+		t.Fatalf("after 2 ticks: shield %d for %d turns, want cleared", mark.ShieldPoints, mark.ShieldTurns)
+	}
+
+	mark.TakeDamage(1, DamageNormal)
+	if mark.Health != 0 {
+		t.Errorf("health after expired shield = %d, want 0", mark.Health)
+	}
+}
+
+func TestUntimedShieldLasts(t *testing.T) {
+
+	mark := &MarkEffect{Owner: ownerA, Health: 1, IsWinEffect: true}
+	mark.ApplyBuff(&MarkEffect{Shield: 3})
+
+	b := testBoard("classic")
+	b.Slots[0].Effects = []*MarkEffect{mark}
+	for i := 0; i < 10; i++ {
+		b.TickBuffs(ownerA)
+	}
+
+	if mark.ShieldPoints != 3 {
+		t.Errorf("shield after 10 ticks = %d, want 3", mark.ShieldPoints)
+	}
+}
+
+func TestFortifyBlocksNonPureForTurns(t *testing.T) {
+
+	b := testBoard("classic")
+	placeMarks(b, ownerA, 0)
+
+	if err := b.ResolveBuffPlay(buffCard("null", MarkEffect{FortifyTurns: 2}), &Player{ID: ownerA}, 0); err != nil {
+		t.Fatalf("ResolveBuffPlay: %v", err)
+	}
+
+	mark := markOn(b, 0)
+
+	for turn := 0; turn < 2; turn++ {
+		mark.TakeDamage(10, DamageNormal)
+		if mark.Health != 1 {
+			t.Fatalf("turn %d: fortified mark health = %d, want 1", turn, mark.Health)
+		}
+		b.TickBuffs(ownerA)
+	}
+
+	if mark.FortifiedTurns != 0 {
+		t.Fatalf("fortify turns left = %d, want 0", mark.FortifiedTurns)
+	}
+
+	mark.TakeDamage(1, DamageNormal)
+	if mark.Health != 0 {
+		t.Errorf("health once fortify ended = %d, want 0", mark.Health)
+	}
+}
+
+func TestFortifyDoesNotBlockPure(t *testing.T) {
+
+	mark := &MarkEffect{Owner: ownerA, Health: 1, IsWinEffect: true}
+	mark.ApplyBuff(&MarkEffect{FortifyTurns: 3})
+
+	mark.TakeDamage(1, DamagePure)
+
+	if mark.Health != 0 {
+		t.Errorf("health = %d, want 0", mark.Health)
+	}
+}
+
+func TestBuffWithoutOwnMarksRejected(t *testing.T) {
+
+	b := testBoard("classic")
+	placeMarks(b, ownerB, 4)
+	placeNonWin(b, ownerA, 4)
+
+	err := b.ResolveBuffPlay(buffCard("radius", MarkEffect{Heal: 1}), &Player{ID: ownerA}, 4)
+	if !errors.Is(err, ErrNoOwnMarks) {
+		t.Fatalf("err = %v, want ErrNoOwnMarks", err)
+	}
+
+	if got := markOn(b, 4).Health; got != 1 {
+		t.Errorf("enemy mark health = %d, want 1", got)
+	}
+}
+
+func placeCard(shape string, stackable bool) *Card { //Returns a card placing a mark over the shape, singular for "null".
+
+	impact := "multiple"
+	if shape == "null" {
+		impact = "singular"
+	}
+
+	return &Card{Name: "Test Mark", Type: "attack", ImpactType: impact, ImpactShape: shape, MarkEffect: &MarkEffect{
+		Health: 1, DamageType: DamagePlace, IsWinEffect: true, IsDisplayable: true, IsStackable: stackable,
+	}}
+}
+
+func TestPlacementStacking(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		card      *Card
+		target    int
+		wantErr   error
+		wantSlots []int //Slots the mark is placed on.
+	}{
+		{name: "stackable on an occupied slot", card: placeCard("null", true), target: 4, wantSlots: []int{4}},
+		{name: "stackable on an empty slot", card: placeCard("null", true), target: 0, wantSlots: []int{0}},
+		{name: "non-stackable on an occupied slot", card: placeCard("null", false), target: 4, wantErr: ErrNotStackable},
+		{name: "non-stackable on a hidden effect", card: placeCard("null", false), target: 2, wantSlots: []int{2}},
+		{name: "non-stackable on an empty slot", card: placeCard("null", false), target: 0, wantSlots: []int{0}},
+		{name: "non-stackable shape skips occupied slots", card: placeCard("cross", false), target: 1, wantSlots: []int{0, 2}},
+		{name: "stackable shape fills every slot", card: placeCard("cross", true), target: 1, wantSlots: []int{0, 1, 2, 4}},
+		{name: "non-stackable shape with every slot occupied", card: placeCard("cross", false), target: 7, wantErr: ErrNotStackable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			b := testBoard("classic")
+			placeMarks(b, ownerB, 1, 4, 6, 7, 8)
+			b.ReturnSlotFromID(2).Effects = []*MarkEffect{{Owner: ownerB, Health: 1}} //Hidden, like a mine.
+
+			_, placeable, err := b.ValidateCardPlay(tt.card, &Player{ID: ownerA}, tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			got := []int{}
+			for _, sl := range placeable {
+				got = append(got, sl.ID)
+			}
+			slices.Sort(got)
+
+			if !slices.Equal(got, tt.wantSlots) {
+				t.Errorf("placeable = %v, want %v", got, tt.wantSlots)
+			}
+		})
+	}
+}
+
+func TestFortifyLastsEnemyTurns(t *testing.T) { //Fortify for 2 turns covers both of the opponent's next 2 turns.
+
+	room, _, _ := seededGame(t, 1)
+	owner := room.ActivePlayer()
+
+	placeMarks(room.Board, owner.ID, 0)
+	if err := room.Board.ResolveBuffPlay(buffCard("null", MarkEffect{FortifyTurns: 2}), owner, 0); err != nil {
+		t.Fatalf("ResolveBuffPlay: %v", err)
+	}
+	mark := markOn(room.Board, 0)
+
+	for turn := 1; turn <= 2; turn++ {
+		room.EndTurn()
+		if room.ActivePlayer() == owner {
+			t.Fatalf("enemy turn %d: still the owner's turn", turn)
+		}
+		if mark.FortifiedTurns == 0 {
+			t.Errorf("enemy turn %d: mark no longer fortified", turn)
+		}
+		room.EndTurn()
+	}
+
+	if mark.FortifiedTurns != 0 {
+		t.Errorf("fortify turns left after 2 enemy turns = %d, want 0", mark.FortifiedTurns)
+	}
+}
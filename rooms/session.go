@@ -0,0 +1,191 @@
+package rooms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var ReconnectGrace = 30 * time.Second //How long a disconnected player's seat is kept during a game.
+
+var sessions = make(map[string]*Player) //Used for retrieving players by resume token. Global.
+var sessionsMu sync.RWMutex             //Read-Write Mutex allows multiple readers, one write.
+
+var ErrUnknownSession = errors.New("unknown or expired resume token")
+
+func NewResumeToken() string { //Returns a random token players resume their session with.
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err) //crypto/rand never fails on supported platforms.
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+func RegisterSession(player *Player) { //Gives the player a resume token and sends it to them.
+
+	player.Mu.Lock()
+	player.ResumeToken = NewResumeToken()
+	player.Connected = true
+	token := player.ResumeToken
+	player.Mu.Unlock()
+
+	sessionsMu.Lock()
+	sessions[token] = player
+	sessionsMu.Unlock()
+
+	msg := GameMessage{ //Create game message to send to clients.
+		Type:        "session",
+		PlayerID:    player.ID.String(),
+		ResumeToken: token,
+	}
+
+	SendMessageToPlayer(player, ConvertMsgToJson(&msg))
+}
+
+func ForgetSession(player *Player) { //Removes the player's resume token so it can no longer be used.
+
+	player.Mu.Lock()
+	token := player.ResumeToken
+	player.Mu.Unlock()
+
+	sessionsMu.Lock()
+	delete(sessions, token)
+	sessionsMu.Unlock()
+}
+
+func DisconnectPlayer(player *Player, conn *websocket.Conn) { //Handles a dropped connection. Players in a game keep their seat for the grace period, others are removed.
+
+	player.Mu.Lock()
+	if player.Conn != conn { //An older connection closing after the player resumed.
+		player.Mu.Unlock()
+		return
+	}
+	player.Mu.Unlock()
+
+	room := FindRoomByPlayer(player)
+	if room == nil { //Queued or between rooms.
+		if HoldDisconnect(player) { //Matched, the matchmaker disconnects them once they are seated.
+			return
+		}
+		LeaveQueue(player)
+		ForgetSession(player)
+		player.Close()
+		return
+	}
+
+	room.Mu.Lock()
+	inGame := room.State == StateInProgress
+	room.Mu.Unlock()
+
+	if !inGame { //Nothing to come back to.
+		ForgetSession(player)
+		room.RemovePlayerFromRoom(player)
+		player.Close()
+		return
+	}
+
+	player.Mu.Lock()
+	player.Connected = false
+	player.disconnects++
+	seq := player.disconnects
+	reconnectBy := time.Now().Add(ReconnectGrace)
+	player.Mu.Unlock()
+
+	fmt.Println("Player disconnected, holding seat:", player.ID)
+
+	room.Mu.Lock()
+	room.NotifyOpponents(player, &GameMessage{Type: "opponent_disconnected", ReconnectBy: reconnectBy.UnixMilli()})
+	room.Mu.Unlock()
+
+	time.AfterFunc(ReconnectGrace, func() {
+		player.Mu.Lock()
+		expired := !player.Connected && player.disconnects == seq
+		player.Mu.Unlock()
+
+		if !expired { //Player came back.
+			return
+		}
+
+		fmt.Println("Reconnect grace expired for player:", player.ID)
+
+		ForgetSession(player)
+		room.RemovePlayerFromRoom(player)
+		player.Close()
+	})
+}
+
+func ResumeSession(token string, conn *websocket.Conn) (*Player, error) { //Reattaches a connection to the player holding the token and sends them the game state.
+
+	sessionsMu.RLock()
+	player := sessions[token]
+	sessionsMu.RUnlock()
+
+	if player == nil {
+		return nil, ErrUnknownSession
+	}
+
+	room := FindRoomByPlayer(player)
+	if room == nil {
+		return nil, ErrUnknownSession
+	}
+
+	player.Mu.Lock()
+	old := player.Conn
+	player.Conn = conn
+	player.Connected = true
+	player.disconnects++ //Stops any running grace timer.
+	player.Mu.Unlock()
+
+	if old != nil && old != conn { //Taking over from a connection that has not noticed it dropped.
+		old.Close()
+	}
+
+	fmt.Println("Player resumed session:", player.ID)
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	SendMessageToPlayer(player, ConvertMsgToJson(room.Snapshot(player)))
+
+	room.NotifyOpponents(player, &GameMessage{Type: "opponent_reconnected"})
+
+	return player, nil
+}
+
+func (room *Room) Snapshot(player *Player) *GameMessage { //Returns everything the player needs to redraw the game. Room mutex must be held.
+
+	boardCfg := room.Board.Config()
+	yourTurn := player.Turn
+
+	return &GameMessage{
+		Type:        "resume",
+		PlayerID:    player.ID.String(),
+		Faction:     player.Faction,
+		State:       string(room.State),
+		Board:       &boardCfg,
+		Rules:       &room.Rules,
+		BoardState:  room.SendBoardState(player),
+		AddCards:    player.Hand,
+		YourTurn:    &yourTurn,
+		Deadline:    room.deadlineMillis(),
+		BankLeft:    player.BankLeft.Milliseconds(),
+		Result:      room.Result,
+		ResumeToken: player.ResumeToken,
+	}
+}
+
+func (room *Room) NotifyOpponents(player *Player, msg *GameMessage) { //Sends the message to every other player in the room. Room mutex must be held.
+
+	for _, pl := range room.Players {
+		if pl.ID != player.ID {
+			SendMessageToPlayer(pl, ConvertMsgToJson(msg))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package rooms
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// ShapeDef declares which slots an impact shape covers. The covered slots are the union of every part that is set.
+type ShapeDef struct {
+	Offsets    [][2]int //Slots relative to the target as {row, col}, {0, 0} is the target.
+	Row        bool     //Covers the target's full row.
+	Col        bool     //Covers the target's full column.
+	Diagonals  bool     //Covers both full diagonals through the target.
+	Random     int      //Covers this many random slots anywhere on the board.
+	EnemyMarks bool     //Covers every slot holding a winnable mark of another player.
+}
+
+var shapes = map[string]*ShapeDef{ //Impact shapes cards, fuses and triggers can reference by name.
+	"null":        {Offsets: [][2]int{{0, 0}}},                                                                       //Only the target.
+	"lines":       {Row: true, Col: true},                                                                            //Bomberman style, the full row and column of the target.
+	"radius":      {Offsets: [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 0}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}}, //1 slot around the target.
+	"row":         {Row: true},
+	"col":         {Col: true},
+	"diagonals":   {Diagonals: true},
+	"cross":       {Offsets: [][2]int{{-1, 0}, {0, -1}, {0, 0}, {0, 1}, {1, 0}}},                               //The target and its 4 neighbours.
+	"knight":      {Offsets: [][2]int{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}}, //Chess knight moves from the target.
+	"random_3":    {Random: 3},
+	"enemy_marks": {EnemyMarks: true},
+}
+var shapesMu sync.RWMutex
+
+func RegisterShape(name string, def ShapeDef) error { //Adds a named shape to the registry. Shapes must be registered before the catalogue that uses them is loaded.
+
+	if name == "" {
+		return errors.New("shape name is empty")
+	}
+	if def.Random < 0 {
+		return fmt.Errorf("shape %q has a negative random count", name)
+	}
+	if len(def.Offsets) == 0 && !def.Row && !def.Col && !def.Diagonals && def.Random == 0 && !def.EnemyMarks {
+		return fmt.Errorf("shape %q covers no slots", name)
+	}
+
+	shapesMu.Lock()
+	defer shapesMu.Unlock()
+
+	if _, ok := shapes[name]; ok {
+		return fmt.Errorf("shape %q is already registered", name)
+	}
+
+	shapes[name] = &def
+
+	return nil
+}
+
+func LookupShape(name string) (*ShapeDef, bool) { //Returns the registered shape with the name.
+
+	shapesMu.RLock()
+	defer shapesMu.RUnlock()
+
+	def, ok := shapes[name]
+
+	return def, ok
+}
+
+func ShapeNames() []string { //Returns the names of the registered shapes, sorted.
+
+	shapesMu.RLock()
+	defer shapesMu.RUnlock()
+
+	names := []string{}
+	for name := range shapes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (b *Board) ShapeSlots(def *ShapeDef, target *Slot, owner uuid.UUID) []*Slot { //Returns the slots the shape covers around the target, in slot ID order.
+
+	covered := make(map[int]bool)
+
+	for _, off := range def.Offsets {
+		if sl := b.ReturnSlotFromPos(target.Row+off[0], target.Col+off[1]); sl != nil {
+			covered[sl.ID] = true
+		}
+	}
+
+	for _, sl := range b.Slots {
+		dRow := sl.Row - target.Row
+		dCol := sl.Col - target.Col
+
+		switch {
+		case def.Row && dRow == 0:
+		case def.Col && dCol == 0:
+		case def.Diagonals && abs(dRow) == abs(dCol):
+		case def.EnemyMarks && sl.HasEnemyMark(owner):
+		default:
+			continue
+		}
+
+		covered[sl.ID] = true
+	}
+
+	if def.Random > 0 { //Random picks use the room's random source so games can be replayed.
+		for _, i := range b.perm(len(b.Slots))[:min(def.Random, len(b.Slots))] {
+			covered[b.Slots[i].ID] = true
+		}
+	}
+
+	retSlots := []*Slot{}
+	for _, sl := range b.Slots { //Slot order keeps resolution deterministic.
+		if covered[sl.ID] {
+			retSlots = append(retSlots, sl)
+		}
+	}
+
+	return retSlots
+}
+
+func (b *Board) perm(n int) []int { //Returns a random permutation of [0, n) from the board's random source.
+
+	if b.rng == nil {
+		return rand.Perm(n)
+	}
+
+	return b.rng.Perm(n)
+}
+
+func (sl *Slot) HasEnemyMark(owner uuid.UUID) bool { //Returns true if the slot holds a winnable mark not owned by owner.
+
+	for _, eff := range sl.Effects {
+		if eff.IsWinEffect && eff.Owner != owner {
+			return true
+		}
+	}
+
+	return false
+}
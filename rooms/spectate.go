@@ -0,0 +1,219 @@
+package rooms
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var SpectatorDelay time.Duration //How far behind the game spectators are kept, so they cannot pass on what they see. 0 sends live.
+
+var ErrNotSpectatable = errors.New("room cannot be spectated")
+var ErrSpectator = errors.New("spectators cannot act in the game")
+
+// Spectator is a connection watching a room. Spectators are not players and never see hands.
+type Spectator struct {
+	Player *Player //Connection and send queue of the spectator.
+	since  int     //Sequence of the first spectator message they receive.
+}
+
+type spectatorMessage struct { //A message waiting out the spectator delay.
+	seq int
+	at  time.Time
+	msg string
+}
+
+// RoomSummary describes a live room for the spectate list.
+type RoomSummary struct {
+	ID         uuid.UUID         `json:"id"`
+	State      RoomState         `json:"state"`
+	Options    RoomOptions       `json:"options"`
+	Players    map[string]string `json:"players"`    //Player name by faction.
+	Ranked     bool              `json:"ranked"`     //If the game is rated.
+	Spectators int               `json:"spectators"` //Number of spectators watching.
+}
+
+func (room *Room) Spectatable() bool { //Returns true if the room is public and its game is under way. Room mutex must be held.
+	return !room.Private && (room.State == StateStarting || room.State == StateInProgress)
+}
+
+func (rc *RoomController) FindRoomByID(id uuid.UUID) *Room { //Returns the room with the ID, or nil.
+
+	rc.Mu.Lock()
+	defer rc.Mu.Unlock()
+
+	for _, room := range rc.Rooms {
+		if room.ID == id {
+			return room
+		}
+	}
+
+	return nil
+}
+
+func (rc *RoomController) LiveRooms() []RoomSummary { //Returns the rooms that can be spectated.
+
+	rc.Mu.Lock()
+	rooms := append([]*Room{}, rc.Rooms...)
+	rc.Mu.Unlock()
+
+	live := []RoomSummary{}
+
+	for _, room := range rooms {
+
+		room.Mu.Lock()
+
+		if room.Spectatable() {
+			summary := RoomSummary{
+				ID:      room.ID,
+				State:   room.State,
+				Options: room.Options(),
+				Players: map[string]string{},
+				Ranked:  room.RatedSeats != nil,
+			}
+			for _, pl := range room.Players {
+				summary.Players[pl.Faction] = pl.Name
+			}
+
+			room.specMu.Lock()
+			summary.Spectators = len(room.Spectators)
+			room.specMu.Unlock()
+
+			live = append(live, summary)
+		}
+
+		room.Mu.Unlock()
+	}
+
+	return live
+}
+
+func (room *Room) AddSpectator(player *Player) error { //Adds a spectator and sends them the game as it stands, after the spectator delay.
+
+	room.Mu.Lock()
+	defer room.Mu.Unlock()
+
+	if !room.Spectatable() {
+		return ErrNotSpectatable
+	}
+
+	room.specMu.Lock()
+	room.Spectators = append(room.Spectators, &Spectator{Player: player, since: room.specSeq + 1})
+	room.specMu.Unlock()
+
+	fmt.Println("Spectator joined room:", room.ID)
+
+	room.SendToSpectators(room.SpectatorSnapshot())
+
+	return nil
+}
+
+func (room *Room) RemoveSpectator(player *Player) { //Removes the spectator, after which nothing more is sent to them.
+
+	room.specMu.Lock()
+	defer room.specMu.Unlock()
+
+	for i, sp := range room.Spectators {
+		if sp.Player.ID == player.ID {
+			room.Spectators = append(room.Spectators[:i], room.Spectators[i+1:]...)
+			return
+		}
+	}
+}
+
+func (room *Room) SpectatorSnapshot() *GameMessage { //Returns the board, rules and whose turn it is, without either hand. Room mutex must be held.
+
+	boardCfg := room.Board.Config()
+
+	return &GameMessage{
+		Type:       "spectate",
+		State:      string(room.State),
+		Board:      &boardCfg,
+		Rules:      &room.Rules,
+		BoardState: room.SendBoardState(nil),
+		Faction:    room.activeFaction(),
+		Deadline:   room.deadlineMillis(),
+		Result:     room.Result,
+	}
+}
+
+func (room *Room) activeFaction() string { //Returns the faction whose turn it is, empty if no one is playing. Room mutex must be held.
+
+	if active := room.ActivePlayer(); active != nil {
+		return active.Faction
+	}
+
+	return ""
+}
+
+func (room *Room) SendToSpectators(msg *GameMessage) { //Sends a copy of the message without cards to every spectator, after the spectator delay. Room mutex must be held.
+
+	room.specMu.Lock()
+	watched := len(room.Spectators) > 0
+	room.specMu.Unlock()
+
+	if !watched {
+		return
+	}
+
+	safe := *msg //Spectators never see hands.
+	safe.AddCards = nil
+	safe.RemoveCards = nil
+	safe.PlayerID = ""
+	safe.ResumeToken = ""
+	safe.RatingToken = ""
+
+	data := ConvertMsgToJson(&safe)
+
+	room.specMu.Lock()
+
+	room.specSeq++
+	room.specPending = append(room.specPending, spectatorMessage{seq: room.specSeq, at: time.Now().Add(SpectatorDelay), msg: data})
+
+	room.specMu.Unlock()
+
+	if SpectatorDelay <= 0 {
+		room.flushSpectators()
+		return
+	}
+
+	time.AfterFunc(SpectatorDelay, room.flushSpectators)
+}
+
+func (room *Room) flushSpectators() { //Sends spectator messages whose delay has passed, in the order they were sent. Spectators too slow to keep up are dropped.
+
+	room.specMu.Lock()
+
+	now := time.Now()
+	slow := []*Spectator{}
+
+	for len(room.specPending) > 0 && !room.specPending[0].at.After(now) {
+
+		pending := room.specPending[0]
+		room.specPending = room.specPending[1:]
+
+		for _, sp := range room.Spectators {
+			if pending.seq < sp.since { //Nothing from before they joined.
+				continue
+			}
+
+			select { //Never waits on a spectator, the game would wait with it.
+			case sp.Player.SendQueue <- pending.msg:
+			default:
+				slow = append(slow, sp)
+			}
+		}
+
+		room.Spectators = slices.DeleteFunc(room.Spectators, func(sp *Spectator) bool { return slices.Contains(slow, sp) })
+	}
+
+	room.specMu.Unlock()
+
+	for _, sp := range slow { //Ends their connection, their reader then closes the spectator.
+		fmt.Println("Dropped slow spectator:", sp.Player.ID)
+		sp.Player.Conn.Close()
+	}
+}
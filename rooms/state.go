@@ -0,0 +1,118 @@
+package rooms
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// RoomState is the stage of the game a room is in.
+type RoomState string
+
+const ( //Room states.
+	StateWaiting    RoomState = "waiting"     //Waiting for players to join.
+	StateStarting   RoomState = "starting"    //Room is full, the game is about to start.
+	StateInProgress RoomState = "in_progress" //The game is being played.
+	StateFinished   RoomState = "finished"    //The game ended with a result.
+	StateAbandoned  RoomState = "abandoned"   //Every player left before the game ended.
+)
+
+var stateTransitions = map[RoomState][]RoomState{ //The states each state can move to.
+	StateWaiting:    {StateStarting, StateAbandoned},
+	StateStarting:   {StateInProgress, StateWaiting, StateAbandoned}, //Back to waiting if a player leaves before the start.
+	StateInProgress: {StateFinished, StateAbandoned},
+	StateFinished:   {},
+	StateAbandoned:  {},
+}
+
+var actionStates = map[string][]RoomState{ //The states each player action is accepted in.
+	"select_deck": {StateWaiting, StateStarting},
+	"play_card":   {StateInProgress},
+	"end_turn":    {StateInProgress},
+	"join_queue":  {StateWaiting}, //Players can only queue before their game starts.
+
+	"create_private_room": {StateWaiting}, //Players can only move rooms before their game starts.
+	"join_room":           {StateWaiting},
+}
+
+// StateError is returned when a transition or action is not allowed in the room's state.
+type StateError struct {
+	State  RoomState //The room's state.
+	Action string    //The rejected action, or the state that could not be moved to.
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("%s not allowed while room is %s", e.Action, e.State)
+}
+
+// StateHook is called after a room changes state, with the room mutex held.
+type StateHook func(room *Room, from RoomState, to RoomState)
+
+var stateHooks = []StateHook{}
+var stateHooksMu sync.RWMutex
+
+// StateChangedEvent is recorded when a room changes state.
+type StateChangedEvent struct {
+	From RoomState `json:"from"`
+	To   RoomState `json:"to"`
+}
+
+func OnRoomState(hook StateHook) { //Registers a hook called after every room state transition.
+
+	stateHooksMu.Lock()
+	defer stateHooksMu.Unlock()
+
+	stateHooks = append(stateHooks, hook)
+}
+
+func (room *Room) Transition(to RoomState) error { //Moves the room to a new state if the transition is legal, recording and broadcasting it. Room mutex must be held.
+
+	from := room.State
+
+	if !room.CanTransition(to) {
+		return &StateError{State: from, Action: "moving to " + string(to)}
+	}
+
+	room.State = to
+
+	fmt.Println("Room", room.ID, "state:", from, "->", to)
+
+	room.Record(EventStateChanged, StateChangedEvent{From: from, To: to})
+
+	msg := GameMessage{ //Telling players the room changed state.
+		Type:  "room_state",
+		State: string(to),
+	}
+	for _, pl := range room.Players {
+		SendMessageToPlayer(pl, ConvertMsgToJson(&msg))
+	}
+	room.SendToSpectators(&msg)
+
+	stateHooksMu.RLock()
+	hooks := append([]StateHook{}, stateHooks...)
+	stateHooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(room, from, to)
+	}
+
+	return nil
+}
+
+func (room *Room) CanTransition(to RoomState) bool { //Returns true if the room can move to the state. Room mutex must be held.
+	return slices.Contains(stateTransitions[room.State], to)
+}
+
+func (room *Room) CheckAction(action string) error { //Returns an error if the action is unknown or not allowed in the room's state. Room mutex must be held.
+
+	states, ok := actionStates[action]
+	if !ok {
+		return fmt.Errorf("unknown action %q", action)
+	}
+
+	if !slices.Contains(states, room.State) {
+		return &StateError{State: room.State, Action: action}
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package rooms
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+const ( //Card actions, what a card does with its targets.
+	ActionEffect = ""     //Resolves the card's effect on each target in order.
+	ActionSwap   = "swap" //Swaps the top marks of the two targets.
+	ActionMove   = "move" //Moves the top mark of the first target to the second.
+)
+
+var validCardActions = []string{ActionEffect, ActionSwap, ActionMove}
+
+var ErrTargetCount = errors.New("wrong number of targets for card") //The play sent a different number of targets than the card has steps.
+
+// TargetStep is one target a card is played on.
+type TargetStep struct {
+	Targeting string //Which slots the step can target.
+	Range     int    //Max distance from the previous step's target, 0 is unlimited.
+}
+
+func (c *Card) TargetSteps() []TargetStep { //Returns the card's steps, a single step using the card's targeting if none are defined.
+
+	if len(c.Steps) > 0 {
+		return c.Steps
+	}
+
+	return []TargetStep{{Targeting: c.Targeting}}
+}
+
+func (b *Board) ResolveTargets(card *Card, player *Player, targets []int, rng *rand.Rand) error { //Validates and resolves every step of the card. The board is unchanged if any step is rejected, and only the cancelling trigger resolves if the card is cancelled.
+
+	steps := card.TargetSteps()
+	if len(targets) != len(steps) {
+		return fmt.Errorf("%w: %s needs %d, got %d", ErrTargetCount, card.Name, len(steps), len(targets))
+	}
+
+	snap := b.snapshot() //Restored if a later step fails so half valid plays change nothing.
+
+	if err := b.resolveSteps(card, player, steps, targets, rng); err != nil {
+		b.restore(snap)
+
+		var cancel *CancelError
+		if errors.As(err, &cancel) { //The whole card is cancelled, used up with nothing but the trigger resolved.
+			b.cancelCard(player, cancel.Targeted)
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (b *Board) cancelCard(player *Player, targeted []*Slot) { //Fires the on_target triggers that cancelled the card against the board as it was before the card.
+
+	pipeline := b.NewTriggerPipeline()
+	pipeline.CheckTarget(player, targeted)
+	pipeline.Run()
+}
+
+func (b *Board) resolveSteps(card *Card, player *Player, steps []TargetStep, targets []int, rng *rand.Rand) error { //Resolves the card's steps in order.
+
+	if card.Action == ActionEffect { //Each step resolves against the board left by the previous one.
+		for i, step := range steps {
+			if err := b.ValidateStep(card, step, player, targets, i); err != nil {
+				return err
+			}
+			if err := b.resolveEffect(card, player, targets[i], rng); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, step := range steps { //Moves and swaps need every target before acting.
+		if err := b.ValidateStep(card, step, player, targets, i); err != nil {
+			return err
+		}
+	}
+
+	from := b.ReturnSlotFromID(targets[0])
+	to := b.ReturnSlotFromID(targets[1])
+
+	pipeline := b.NewTriggerPipeline()
+	if pipeline.CheckTarget(player, []*Slot{from, to}) { //Wards watch moves and swaps too.
+		fmt.Println("Card cancelled by a trigger.")
+		return &CancelError{Targeted: []*Slot{from, to}}
+	}
+	pipeline.Run() //Reacting to anything the watching triggers destroyed.
+
+	switch card.Action {
+	case ActionSwap:
+		b.SwapMarks(from, to, player)
+	case ActionMove:
+		b.MoveMark(from, to, player)
+	}
+
+	return nil
+}
+
+func (b *Board) resolveEffect(card *Card, player *Player, tarSlotID int, rng *rand.Rand) error { //Resolves the card's effect on one target.
+
+	switch card.Type { //Checking card type.
+	case "attack": //If card is an attack type. (i.e. damages other marks, places marks etc)
+		return b.ResolveCardPlay(card, player, tarSlotID, rng)
+	case "buff": //If card is a buff type (i.e. effects that add health.)
+		return b.ResolveBuffPlay(card, player, tarSlotID)
+	}
+
+	return nil
+}
+
+func (b *Board) ValidateStep(card *Card, step TargetStep, player *Player, targets []int, i int) error { //Checks the i-th target against its step.
+
+	tarSlotID := targets[i]
+
+	if err := b.ValidateTarget(card, step.Targeting, player, tarSlotID); err != nil {
+		return err
+	}
+
+	if i == 0 {
+		return nil
+	}
+
+	for _, prev := range targets[:i] { //Each step needs its own slot.
+		if prev == tarSlotID {
+			return &TargetError{Card: card.Name, SlotID: tarSlotID, Rule: step.Targeting, Reason: "slot was already targeted"}
+		}
+	}
+
+	if step.Range > 0 {
+		prev := b.ReturnSlotFromID(targets[i-1])
+		target := b.ReturnSlotFromID(tarSlotID)
+		if max(abs(prev.Row-target.Row), abs(prev.Col-target.Col)) > step.Range {
+			return &TargetError{Card: card.Name, SlotID: tarSlotID, Rule: step.Targeting, Reason: fmt.Sprintf("slot is more than %d away from the previous target", step.Range)}
+		}
+	}
+
+	return nil
+}
+
+func (b *Board) SwapMarks(a *Slot, c *Slot, player *Player) { //Swaps the top marks of two slots. Moved marks trigger on_place on their new slot.
+
+	markA := a.TopMark()
+	markC := c.TopMark()
+
+	a.RemoveEffect(markA)
+	c.RemoveEffect(markC)
+
+	pipeline := b.NewTriggerPipeline()
+
+	if markA != nil {
+		c.Effects = append(c.Effects, markA)
+		pipeline.Emit(TriggerEvent{Kind: TriggerOnPlace, Slot: c, Effect: markA, Player: player})
+	}
+	if markC != nil {
+		a.Effects = append(a.Effects, markC)
+		pipeline.Emit(TriggerEvent{Kind: TriggerOnPlace, Slot: a, Effect: markC, Player: player})
+	}
+
+	pipeline.Run()
+}
+
+func (b *Board) MoveMark(from *Slot, to *Slot, player *Player) { //Moves the top mark of from to to. The moved mark triggers on_place on its new slot.
+
+	mark := from.TopMark()
+	if mark == nil {
+		return
+	}
+
+	from.RemoveEffect(mark)
+	to.Effects = append(to.Effects, mark)
+
+	pipeline := b.NewTriggerPipeline()
+	pipeline.Emit(TriggerEvent{Kind: TriggerOnPlace, Slot: to, Effect: mark, Player: player})
+	pipeline.Run()
+}
+
+func (sl *Slot) TopMark() *MarkEffect { //Returns the last placed winnable mark on the slot, or nil.
+
+	for i := len(sl.Effects) - 1; i >= 0; i-- {
+		if sl.Effects[i].IsWinEffect {
+			return sl.Effects[i]
+		}
+	}
+
+	return nil
+}
+
+type boardSnapshot struct { //Copy of the board's effects, used to undo rejected plays.
+	effects [][]MarkEffect
+	changes []EffectChange
+}
+
+func (b *Board) snapshot() boardSnapshot { //Copies every effect on the board.
+
+	snap := boardSnapshot{effects: make([][]MarkEffect, len(b.Slots)), changes: slices.Clone(b.changes)}
+
+	for i, sl := range b.Slots {
+		if sl.Effects == nil { //Kept nil so a restored board is identical.
+			continue
+		}
+		snap.effects[i] = make([]MarkEffect, 0, len(sl.Effects))
+		for _, eff := range sl.Effects {
+			snap.effects[i] = append(snap.effects[i], *eff)
+		}
+	}
+
+	return snap
+}
+
+func (b *Board) restore(snap boardSnapshot) { //Puts the board's effects back to the snapshot.
+
+	for i, sl := range b.Slots {
+		if snap.effects[i] == nil {
+			sl.Effects = nil
+			continue
+		}
+		sl.Effects = make([]*MarkEffect, 0, len(snap.effects[i]))
+		for _, eff := range snap.effects[i] {
+			restored := eff
+			sl.Effects = append(sl.Effects, &restored)
+		}
+	}
+
+	b.changes = snap.changes
+}
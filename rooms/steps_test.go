@@ -0,0 +1,162 @@
+package rooms
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func doubleMark() *Card { //Returns a card placing a mark on each of two empty slots, like Double Mark.
+	return &Card{Name: "Test Double Mark", Type: "attack", ImpactType: "singular", ImpactShape: "null",
+		Steps:      []TargetStep{{Targeting: TargetEmpty}, {Targeting: TargetEmpty}},
+		MarkEffect: &MarkEffect{Health: 1, DamageType: DamagePlace, IsStackable: true, IsWinEffect: true, IsDisplayable: true, IsDestroyable: true},
+	}
+}
+
+func swapCard() *Card { //Returns a card swapping one of the player's marks with an enemy mark, like Swap.
+	return &Card{Name: "Test Swap", Type: "attack", ImpactType: "singular", ImpactShape: "null", Action: ActionSwap,
+		Steps:      []TargetStep{{Targeting: TargetOwnMark}, {Targeting: TargetEnemyMark}},
+		MarkEffect: &MarkEffect{},
+	}
+}
+
+func placeWard(b *Board, owner uuid.UUID, slotID int) *MarkEffect { //Places a ward cancelling the first enemy card targeting its row.
+	ward := &MarkEffect{InstanceID: uuid.New(), Owner: owner, Health: 1, IsDisplayable: true,
+		Trigger: TriggerOnTarget, TriggerShape: "row", TriggerCharges: 1, TriggerCancels: true}
+	sl := b.ReturnSlotFromID(slotID)
+	sl.Effects = append(sl.Effects, ward)
+	return ward
+}
+
+func ownedSlots(b *Board, owner uuid.UUID) []int { //Returns the slots holding a winnable mark of the owner.
+	ids := []int{}
+	for _, sl := range b.Slots {
+		if sl.HasWinMark(owner) {
+			ids = append(ids, sl.ID)
+		}
+	}
+	return ids
+}
+
+func cancelled(b *Board) bool { //Returns true if the board recorded a cancelled card.
+	return slices.ContainsFunc(b.changes, func(c EffectChange) bool { return c.Kind == ChangeCancelled })
+}
+
+func TestWardCancelsWholeCard(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		targets []int
+	}{
+		{name: "first step in the warded row", targets: []int{0, 3}},
+		{name: "second step in the warded row", targets: []int{3, 2}}, //The first mark is undone.
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			b := testBoard("classic")
+			placeWard(b, ownerB, 1)
+
+			if err := b.ResolveTargets(doubleMark(), &Player{ID: ownerA}, tt.targets, nil); err != nil {
+				t.Fatalf("ResolveTargets: %v", err)
+			}
+
+			if got := ownedSlots(b, ownerA); len(got) != 0 {
+				t.Errorf("cancelled card placed marks on %v", got)
+			}
+			if !cancelled(b) {
+				t.Errorf("no cancel recorded")
+			}
+			if len(b.ReturnSlotFromID(1).Effects) != 0 {
+				t.Errorf("ward still on the board after using its only charge")
+			}
+		})
+	}
+}
+
+func TestWardCancelsSwap(t *testing.T) {
+
+	for _, action := range []string{ActionSwap, ActionMove} {
+		t.Run(action, func(t *testing.T) {
+
+			b := testBoard("classic")
+			placeMarks(b, ownerA, 4)
+			placeMarks(b, ownerB, 0)
+			placeWard(b, ownerB, 2)
+
+			card := swapCard()
+			card.Action = action
+
+			if err := b.ResolveTargets(card, &Player{ID: ownerA}, []int{4, 0}, nil); err != nil {
+				t.Fatalf("ResolveTargets: %v", err)
+			}
+
+			if got := ownedSlots(b, ownerA); !slices.Equal(got, []int{4}) {
+				t.Errorf("player's marks on %v, want [4]", got)
+			}
+			if got := ownedSlots(b, ownerB); !slices.Equal(got, []int{0}) {
+				t.Errorf("enemy marks on %v, want [0]", got)
+			}
+			if !cancelled(b) {
+				t.Errorf("no cancel recorded")
+			}
+		})
+	}
+}
+
+func TestWardIgnoresOtherRows(t *testing.T) {
+
+	b := testBoard("classic")
+	placeWard(b, ownerB, 1)
+
+	if err := b.ResolveTargets(doubleMark(), &Player{ID: ownerA}, []int{3, 6}, nil); err != nil {
+		t.Fatalf("ResolveTargets: %v", err)
+	}
+
+	if got := ownedSlots(b, ownerA); !slices.Equal(got, []int{3, 6}) {
+		t.Errorf("marks on %v, want [3 6]", got)
+	}
+	if cancelled(b) {
+		t.Errorf("card cancelled by a ward in another row")
+	}
+}
+
+func TestRejectedStepChangesNothing(t *testing.T) { //Step 1 is valid and fires a mine, step 2 is rejected.
+
+	b := testBoard("classic")
+	mine := &MarkEffect{InstanceID: uuid.New(), Owner: ownerB, DamageType: DamagePure, IsStackable: true,
+		Trigger: TriggerOnPlace, TriggerDamage: 100, TriggerShape: "null", TriggerCharges: 1}
+	b.ReturnSlotFromID(0).Effects = append(b.ReturnSlotFromID(0).Effects, mine)
+	placeMarks(b, ownerB, 4)
+	b.changes = append(b.changes, EffectChange{SlotID: 4, InstanceID: "earlier", Kind: ChangeDamaged, Amount: 1}) //Not yet sent, must survive.
+
+	state := func() string {
+		data, err := json.Marshal(struct {
+			Slots   []*Slot
+			Changes []EffectChange
+		}{b.Slots, b.changes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	before := state()
+
+	if err := b.ResolveTargets(doubleMark(), &Player{ID: ownerA}, []int{0, 4}, nil); err == nil {
+		t.Fatal("second step on an enemy mark was accepted")
+	}
+
+	if after := state(); after != before {
+		t.Errorf("board changed by a rejected card\nbefore: %s\nafter:  %s", before, after)
+	}
+
+	if err := b.ResolveTargets(doubleMark(), &Player{ID: ownerA}, []int{0, 2}, nil); err != nil { //The same first step does change the board once the play is valid.
+		t.Fatalf("ResolveTargets: %v", err)
+	}
+	if state() == before {
+		t.Errorf("valid play left the board unchanged, the mine never fired")
+	}
+}
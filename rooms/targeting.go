@@ -0,0 +1,84 @@
+package rooms
+
+import (
+	"errors"
+	"fmt"
+)
+
+const ( //Card targeting rules, which slots a card can be played on.
+	TargetAny       = "any"        //Any slot on the board.
+	TargetEmpty     = "empty_slot" //Slots without visible effects.
+	TargetEnemyMark = "enemy_mark" //Slots holding a mark of another player.
+	TargetOwnMark   = "own_mark"   //Slots holding one of the player's marks.
+	TargetNone      = "none"       //The card is not played on a slot.
+)
+
+var validTargetings = []string{TargetAny, TargetEmpty, TargetEnemyMark, TargetOwnMark, TargetNone}
+
+const noTargetSlotID int = 0 //Slot untargeted cards resolve from, their shapes do not depend on the target.
+
+var ErrInvalidTarget = errors.New("invalid target") //Wrapped by every TargetError.
+
+// TargetError is returned when a card is played on a slot its targeting rule does not allow.
+type TargetError struct {
+	Card   string //The name of the card played.
+	SlotID int    //The targeted slot.
+	Rule   string //The card's targeting rule.
+	Reason string //Why the slot is not a valid target.
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("%s cannot target slot %d: %s", e.Card, e.SlotID, e.Reason)
+}
+
+func (e *TargetError) Unwrap() error {
+	return ErrInvalidTarget
+}
+
+func (b *Board) ValidateTarget(card *Card, rule string, player *Player, tarSlotID int) error { //Checks the target slot against a targeting rule of the card.
+
+	if rule == TargetNone { //Untargeted cards ignore the sent slot.
+		return nil
+	}
+
+	targetErr := func(reason string) error {
+		return &TargetError{Card: card.Name, SlotID: tarSlotID, Rule: rule, Reason: reason}
+	}
+
+	sl := b.ReturnSlotFromID(tarSlotID)
+	if sl == nil {
+		return targetErr("target slot out of bounds")
+	}
+
+	switch rule {
+	case TargetEmpty:
+		if !sl.IsEmpty() {
+			return targetErr("slot is not empty")
+		}
+	case TargetEnemyMark:
+		if !sl.HasEnemyMark(player.ID) {
+			return targetErr("slot has no enemy mark")
+		}
+	case TargetOwnMark:
+		if !sl.HasWinMark(player.ID) {
+			return targetErr("slot has none of your marks")
+		}
+	}
+
+	return nil
+}
+
+func (sl *Slot) IsEmpty() bool { //Returns true if the slot holds no visible effects. Hidden effects (i.e. mines) do not count so they stay hidden.
+
+	for _, eff := range sl.Effects {
+		if eff.IsDisplayable {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (def *ShapeDef) UsesTarget() bool { //Returns true if the slots the shape covers depend on the target.
+	return len(def.Offsets) > 0 || def.Row || def.Col || def.Diagonals
+}
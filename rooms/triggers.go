@@ -0,0 +1,212 @@
+package rooms
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const ( //Events slot effects can react to.
+	TriggerOnPlace     = "on_place"      //An enemy effect was placed on the trigger's slot.
+	TriggerOnDestroy   = "on_destroy"    //The trigger's own mark was destroyed.
+	TriggerOnTurnStart = "on_turn_start" //The trigger owner's turn started.
+	TriggerOnTarget    = "on_target"     //An enemy card targets a slot the trigger watches.
+)
+
+var validTriggers = []string{"", TriggerOnPlace, TriggerOnDestroy, TriggerOnTurnStart, TriggerOnTarget}
+
+const maxTriggerDepth int = 8     //How many triggers can chain off each other.
+const maxTriggersPerRun int = 64  //How many triggers can fire from a single action.
+const ChangeTriggered = "trigger" //Effect change kind for a trigger firing.
+const ChangeCancelled = "cancel"  //Effect change kind for a card cancelled by a trigger.
+
+// TriggerEvent is an event passed through the trigger pipeline.
+type TriggerEvent struct {
+	Kind   string      //The trigger the event fires (i.e. on_place)
+	Slot   *Slot       //The slot the event happened on.
+	Effect *MarkEffect //The effect the event is about (the placed or destroyed effect).
+	Player *Player     //The player who caused the event, or whose turn started.
+	Depth  int         //How many triggers led to this event.
+}
+
+// TriggerPipeline runs trigger events in order, queueing the events triggers cause.
+// Events are handled first in first out and listeners in slot then stack order, so resolution is deterministic.
+type TriggerPipeline struct {
+	board *Board
+	queue []TriggerEvent
+	fired map[uuid.UUID]bool //Effects that already fired this run, each effect fires at most once per run.
+	count int                //Triggers fired this run.
+}
+
+// SlotEffect is an effect with the slot it is (or was) on.
+type SlotEffect struct {
+	Slot   *Slot
+	Effect *MarkEffect
+}
+
+func (b *Board) NewTriggerPipeline() *TriggerPipeline { //Creates an empty pipeline for the board.
+	return &TriggerPipeline{board: b, queue: []TriggerEvent{}, fired: make(map[uuid.UUID]bool)}
+}
+
+func (tp *TriggerPipeline) Emit(ev TriggerEvent) { //Queues an event.
+	tp.queue = append(tp.queue, ev)
+}
+
+func (tp *TriggerPipeline) EmitDestroyed(destroyed []SlotEffect, player *Player, depth int) { //Queues on_destroy events for destroyed effects.
+	for _, d := range destroyed {
+		tp.Emit(TriggerEvent{Kind: TriggerOnDestroy, Slot: d.Slot, Effect: d.Effect, Player: player, Depth: depth})
+	}
+}
+
+func (tp *TriggerPipeline) Run() { //Handles queued events until the queue is empty or a loop limit is reached.
+
+	for len(tp.queue) > 0 {
+		ev := tp.queue[0]
+		tp.queue = tp.queue[1:]
+
+		if ev.Depth >= maxTriggerDepth { //Loop protection, stop long chains.
+			fmt.Println("Trigger chain too deep, stopping.")
+			continue
+		}
+
+		for _, l := range tp.listeners(ev) {
+			if tp.count >= maxTriggersPerRun { //Loop protection, stop runaway boards.
+				fmt.Println("Too many triggers, stopping.")
+				tp.queue = nil
+				return
+			}
+			tp.fire(l, ev)
+		}
+	}
+}
+
+func (tp *TriggerPipeline) listeners(ev TriggerEvent) []SlotEffect { //Returns the effects that react to the event, in slot then stack order.
+
+	found := []SlotEffect{}
+
+	switch ev.Kind {
+	case TriggerOnPlace: //Effects already on the slot react to enemy placements.
+		for _, eff := range ev.Slot.Effects {
+			if eff != ev.Effect && eff.Trigger == ev.Kind && eff.Owner != ev.Player.ID {
+				found = append(found, SlotEffect{Slot: ev.Slot, Effect: eff})
+			}
+		}
+	case TriggerOnDestroy: //The destroyed effect reacts to its own destruction.
+		if ev.Effect.Trigger == ev.Kind {
+			found = append(found, SlotEffect{Slot: ev.Slot, Effect: ev.Effect})
+		}
+	case TriggerOnTurnStart: //The active player's effects react to their turn starting.
+		for _, sl := range tp.board.Slots {
+			for _, eff := range sl.Effects {
+				if eff.Trigger == ev.Kind && eff.Owner == ev.Player.ID {
+					found = append(found, SlotEffect{Slot: sl, Effect: eff})
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+func (tp *TriggerPipeline) fire(l SlotEffect, ev TriggerEvent) { //Fires the listener's trigger, queueing events for anything it destroys.
+
+	if tp.fired[l.Effect.InstanceID] { //Each effect fires once per run.
+		return
+	}
+	tp.fired[l.Effect.InstanceID] = true
+	tp.count++
+
+	fmt.Println("Trigger", l.Effect.Trigger, "fired on slot", l.Slot.ID)
+
+	tp.board.changes = append(tp.board.changes, EffectChange{SlotID: l.Slot.ID, InstanceID: l.Effect.InstanceID.String(), Kind: ChangeTriggered, Amount: l.Effect.TriggerDamage})
+
+	l.Effect.UseCharge()
+
+	if l.Effect.TriggerDamage > 0 {
+		blast := &MarkEffect{Damage: l.Effect.TriggerDamage, DamageType: l.Effect.DamageType}
+		destroyed := tp.board.ApplyDamageToSlotsFromCard(tp.board.TriggerSlots(l.Effect, l.Slot), blast)
+		tp.EmitDestroyed(destroyed, ev.Player, ev.Depth+1)
+	}
+
+	if l.Effect.Duration < 0 { //Out of charges.
+		l.Slot.RemoveEffect(l.Effect)
+	}
+}
+
+func (tp *TriggerPipeline) CheckTarget(player *Player, targeted []*Slot) bool { //Fires on_target triggers of enemy effects watching the targeted slots. Returns true if the card was cancelled.
+
+	targetedIDs := make(map[int]bool)
+	for _, sl := range targeted {
+		targetedIDs[sl.ID] = true
+	}
+
+	for _, sl := range tp.board.Slots {
+		for _, eff := range sl.Effects {
+			if eff.Trigger != TriggerOnTarget || eff.Owner == player.ID {
+				continue
+			}
+
+			watching := false
+			for _, watched := range tp.board.TriggerSlots(eff, sl) {
+				if targetedIDs[watched.ID] {
+					watching = true
+					break
+				}
+			}
+
+			if !watching {
+				continue
+			}
+
+			tp.fire(SlotEffect{Slot: sl, Effect: eff}, TriggerEvent{Kind: TriggerOnTarget, Slot: sl, Effect: eff, Player: player})
+
+			if eff.TriggerCancels {
+				tp.board.changes = append(tp.board.changes, EffectChange{SlotID: sl.ID, InstanceID: eff.InstanceID.String(), Kind: ChangeCancelled})
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (b *Board) TriggerSlots(eff *MarkEffect, sl *Slot) []*Slot { //Returns the slots a trigger on sl hits or watches.
+
+	if eff.TriggerShape == "" { //No shape, only the trigger's own slot.
+		return []*Slot{sl}
+	}
+
+	return b.GetAffectedSlots(eff.TriggerShape, sl.ID, eff.Owner)
+}
+
+func (m *MarkEffect) UseCharge() { //Uses one trigger charge, marking the effect for removal when none are left. 0 charges never run out.
+
+	if m.TriggerCharges <= 0 {
+		return
+	}
+
+	m.TriggerCharges--
+	if m.TriggerCharges == 0 {
+		m.Duration = -1 //Marks the spent trigger for removal.
+	}
+}
+
+func (sl *Slot) RemoveEffect(eff *MarkEffect) { //Removes the effect from the slot's stack.
+
+	kept := []*MarkEffect{}
+	for _, e := range sl.Effects {
+		if e != eff {
+			kept = append(kept, e)
+		}
+	}
+
+	sl.Effects = kept
+}
+
+func (b *Board) TakeChanges() []EffectChange { //Returns and clears the effect changes from triggers since the last call.
+
+	changes := b.changes
+	b.changes = nil
+
+	return changes
+}
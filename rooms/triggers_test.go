@@ -0,0 +1,123 @@
+package rooms
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func volatileMarks(t *testing.T, b *Board, owner uuid.UUID, slotIDs ...int) { //Places a catalogue Volatile Mark for the owner on each slot.
+
+	_, cardSet, err := ReadCatalogueDir("../cards")
+	if err != nil {
+		t.Fatalf("loading catalogue: %v", err)
+	}
+	card := FindCardByName(cardSet, "Volatile Mark")
+	if card == nil {
+		t.Fatal("catalogue has no Volatile Mark")
+	}
+
+	for _, id := range slotIDs {
+		eff := *card.MarkEffect
+		eff.InstanceID, eff.Owner = uuid.New(), owner
+		sl := b.ReturnSlotFromID(id)
+		sl.Effects = append(sl.Effects, &eff)
+	}
+}
+
+func detonate(b *Board, slotID int) *TriggerPipeline { //Destroys the slot's marks and runs the triggers that follow.
+
+	pipeline := b.NewTriggerPipeline()
+	destroyed := b.ApplyDamageToSlotsFromCard([]*Slot{b.ReturnSlotFromID(slotID)}, &MarkEffect{Damage: 100, DamageType: DamagePure})
+	pipeline.EmitDestroyed(destroyed, &Player{ID: ownerA}, 0)
+	pipeline.Run()
+
+	return pipeline
+}
+
+func firedSlots(b *Board) []int { //Returns the slot of each trigger fired, in the order recorded.
+	ids := []int{}
+	for _, c := range b.changes {
+		if c.Kind == ChangeTriggered {
+			ids = append(ids, c.SlotID)
+		}
+	}
+	return ids
+}
+
+func TestTriggerChainOrder(t *testing.T) {
+
+	want := []int{0, 1, 5, 6, 12} //0 destroys 1, 5 and 6 in slot order, then 6 reaches 12.
+
+	for run := 0; run < 3; run++ {
+		b := testBoard("gomoku_lite")
+		volatileMarks(t, b, ownerB, 12, 6, 5, 1, 0) //Placement order must not matter.
+
+		detonate(b, 0)
+
+		if got := firedSlots(b); !slices.Equal(got, want) {
+			t.Fatalf("run %d: triggers fired on %v, want %v", run, got, want)
+		}
+		if got := ownedSlots(b, ownerB); len(got) != 0 {
+			t.Errorf("run %d: marks left on %v", run, got)
+		}
+	}
+}
+
+func TestTriggerChainDepthLimit(t *testing.T) {
+
+	b := CreateBoard(BoardConfig{Width: 12, Height: 1, WinLength: 3})
+	ids := make([]int, 12)
+	for i := range ids {
+		ids[i] = i
+	}
+	volatileMarks(t, &b, ownerB, ids...)
+
+	detonate(&b, 0)
+
+	if got, want := firedSlots(&b), ids[:maxTriggerDepth]; !slices.Equal(got, want) {
+		t.Errorf("triggers fired on %v, want %v", got, want)
+	}
+	if got, want := ownedSlots(&b, ownerB), ids[maxTriggerDepth+1:]; !slices.Equal(got, want) { //The last blast destroys one more mark, which never fires.
+		t.Errorf("marks left on %v, want %v", got, want)
+	}
+}
+
+func TestTriggerFiresOncePerRun(t *testing.T) {
+
+	b := testBoard("classic")
+	volatileMarks(t, b, ownerB, 4)
+	eff := b.ReturnSlotFromID(4).Effects[0]
+
+	pipeline := b.NewTriggerPipeline()
+	for range 3 { //The same destruction reported several times, as a loop would.
+		pipeline.Emit(TriggerEvent{Kind: TriggerOnDestroy, Slot: b.ReturnSlotFromID(4), Effect: eff, Player: &Player{ID: ownerA}})
+	}
+	pipeline.Run()
+
+	if got := firedSlots(b); !slices.Equal(got, []int{4}) {
+		t.Errorf("triggers fired on %v, want once on 4", got)
+	}
+}
+
+func TestTriggerCountLimit(t *testing.T) {
+
+	b := testBoard("classic")
+	sl := b.ReturnSlotFromID(0)
+	for range maxTriggersPerRun + 10 {
+		sl.Effects = append(sl.Effects, &MarkEffect{InstanceID: uuid.New(), Owner: ownerA, IsStackable: true, Trigger: TriggerOnTurnStart})
+	}
+
+	pipeline := b.NewTriggerPipeline()
+	pipeline.Emit(TriggerEvent{Kind: TriggerOnTurnStart, Player: &Player{ID: ownerA}})
+	pipeline.Emit(TriggerEvent{Kind: TriggerOnTurnStart, Player: &Player{ID: ownerA}}) //Dropped once the limit is hit.
+	pipeline.Run()
+
+	if got := len(firedSlots(b)); got != maxTriggersPerRun {
+		t.Errorf("%d triggers fired, want %d", got, maxTriggersPerRun)
+	}
+	if len(pipeline.queue) != 0 {
+		t.Errorf("%d events left queued after the limit", len(pipeline.queue))
+	}
+}
@@ -0,0 +1,79 @@
+package rooms
+
+import (
+	"errors"
+	"slices"
+)
+
+// TurnRules sets how many cards a player can play each turn.
+type TurnRules struct {
+	PlaysPerTurn int      `json:"plays_per_turn"`       //Card plays allowed each turn.
+	FreeCards    []string `json:"free_cards,omitempty"` //Names of cards that do not use a play.
+	AutoEndTurn  bool     `json:"auto_end_turn"`        //Ends the turn once every play is used, otherwise players send end_turn.
+}
+
+var DefaultTurnRules = TurnRules{PlaysPerTurn: 1, AutoEndTurn: true} //One card per turn, the original rules.
+
+var TurnRulePresets = map[string]TurnRules{ //Named rulesets rooms can be created with.
+	"classic":    DefaultTurnRules,                                                                       //One card, turn ends after it.
+	"double":     {PlaysPerTurn: 2, AutoEndTurn: true},                                                   //Two cards each turn.
+	"manual":     {PlaysPerTurn: 1, AutoEndTurn: false},                                                  //One card, players end their own turn.
+	"free_buffs": {PlaysPerTurn: 1, FreeCards: []string{"Heal", "Shield", "Fortify"}, AutoEndTurn: true}, //Buffs can be played on top of the turn's card.
+}
+
+var ErrNoPlaysLeft = errors.New("no card plays left this turn") //The player has used every play this turn.
+
+func TurnRulesFromName(name string) TurnRules { //Returns the ruleset with the given name, falling back to the default rules.
+
+	if rules, ok := TurnRulePresets[name]; ok {
+		return rules
+	}
+
+	return DefaultTurnRules
+}
+
+func (tr TurnRules) withDefaults() TurnRules { //Fills in rules missing from older configs and replays.
+
+	if tr.PlaysPerTurn <= 0 {
+		return DefaultTurnRules
+	}
+
+	return tr
+}
+
+func (tr TurnRules) IsFree(card *Card) bool { //Returns true if the card does not use a play.
+	return slices.Contains(tr.FreeCards, card.Name)
+}
+
+func (tr TurnRules) Equal(other TurnRules) bool { //Returns true if both rulesets are the same.
+	return tr.PlaysPerTurn == other.PlaysPerTurn && tr.AutoEndTurn == other.AutoEndTurn && slices.Equal(tr.FreeCards, other.FreeCards)
+}
+
+func (room *Room) CheckPlaysLeft(card *Card) error { //Returns ErrNoPlaysLeft if the card needs a play and none are left. Room mutex must be held.
+
+	if !room.Rules.IsFree(card) && room.PlaysMade >= room.Rules.PlaysPerTurn {
+		return ErrNoPlaysLeft
+	}
+
+	return nil
+}
+
+func (room *Room) CountPlay(card *Card) { //Uses one of the turn's plays for a resolved card, free cards use none. Room mutex must be held.
+
+	if !room.Rules.IsFree(card) {
+		room.PlaysMade++
+	}
+}
+
+func (room *Room) TurnUsedUp() bool { //Returns true if the turn should end on its own. Room mutex must be held.
+	return room.Rules.AutoEndTurn && room.PlaysMade >= room.Rules.PlaysPerTurn
+}
+
+func (room *Room) EndPlayerTurn(player *Player) error { //Handles an end_turn action. Room mutex must be held.
+
+	if !player.Turn {
+		return errors.New("not your turn")
+	}
+
+	return nil
+}
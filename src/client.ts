@@ -1,25 +1,58 @@
 const eventBus = new EventTarget();
 export default eventBus;
 
-const socket = new WebSocket("ws://localhost:8080/ws");
+const resumeKey = "resume_token"; //sessionStorage key the server's resume token is kept under.
+const reconnectDelay = 2000; //Milliseconds to wait before reconnecting.
+const ratingKey = "rating_token:"; //localStorage key prefix the token owning each rated name is kept under.
 
-socket.addEventListener("open", () => {
-  console.log("✅ WebSocket connected");
-});
+let socket = connect();
 
-socket.addEventListener("message", (event) => {
-  console.log("📨 Server:", event.data);
-  const messageEvent = new CustomEvent("wsMessage", { detail: event.data });
-  eventBus.dispatchEvent(messageEvent);
-});
+function connect(): WebSocket { //Opens the socket, resuming the previous session if there is a token.
 
-socket.addEventListener("close", () => {
-  console.warn("🔌 WebSocket disconnected");
-});
-
-socket.addEventListener("error", (err) => {
-  console.error("❌ WebSocket error:", err);
-});
+  const token = sessionStorage.getItem(resumeKey);
+  const params = new URLSearchParams(window.location.search); //Passing page query (i.e. ?board=four) to server.
+  const name = params.get("name");
+  const ratingToken = name !== null ? localStorage.getItem(ratingKey + name) : null;
+  if (ratingToken !== null) { //Proving we own the name we are rated under.
+    params.set("rating_token", ratingToken);
+  }
+  const query = token !== null ? "?resume=" + token : "?" + params.toString();
+
+  const ws = new WebSocket("ws://localhost:8080/ws" + query);
+
+  ws.addEventListener("open", () => {
+    console.log("✅ WebSocket connected");
+  });
+
+  ws.addEventListener("message", (event) => {
+    console.log("📨 Server:", event.data);
+
+    const data = JSON.parse(event.data);
+    if (data.type === "session" || data.type === "resume") { //Keeping the token to reconnect with.
+      sessionStorage.setItem(resumeKey, data.resume_token);
+    } else if (data.type === "game_over") { //Nothing left to resume.
+      sessionStorage.removeItem(resumeKey);
+    } else if (data.type === "rating_token" && name !== null) { //Issued the first time we queue under this name.
+      localStorage.setItem(ratingKey + name, data.rating_token);
+    }
+
+    const messageEvent = new CustomEvent("wsMessage", { detail: event.data });
+    eventBus.dispatchEvent(messageEvent);
+  });
+
+  ws.addEventListener("close", () => {
+    console.warn("🔌 WebSocket disconnected");
+    if (sessionStorage.getItem(resumeKey) !== null) { //Trying to get back into the game.
+      setTimeout(() => { socket = connect(); }, reconnectDelay);
+    }
+  });
+
+  ws.addEventListener("error", (err) => {
+    console.error("❌ WebSocket error:", err);
+  });
+
+  return ws;
+}
 
 export function send(data: object) {
   if (socket.readyState === WebSocket.OPEN) {
@@ -28,5 +61,3 @@ export function send(data: object) {
 }
 
 export { socket };
-
-
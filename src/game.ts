@@ -30,6 +30,7 @@ import eventBus from "./client";
     colour: number;
     slotGraphic: PIXI.Graphics;
     markerGraphic: PIXI.Sprite;
+    effects: any[]; //Effects on the slot from the last board state.
   }
 
   let slotSize = window.innerWidth*0.04;
@@ -46,13 +47,20 @@ import eventBus from "./client";
 
   let slotCounter = 0;
 
+  let boardWidth = 3; //Board columns, updated from the server on game start.
+  let boardHeight = 3; //Board rows, updated from the server on game start.
+  let playerID = ""; //This player's ID, sent on game start.
+
   //Creating Card Hand
   let cardSpriteScaler = 1;
   let cardHandSpace = window.innerWidth * 0.01;
 
   interface Card {
+    id:string,
     name:string,
     description:string,
+    targeting:string,
+    steps:any[], //Targets of multi-target cards, in order.
     selected:boolean,
     graphicPath: string,
     markerSprite: PIXI.Sprite,
@@ -79,6 +87,7 @@ import eventBus from "./client";
 
   const cardHand: Card[] = [];
   let selectedCard: Card | undefined;
+  let pendingTargets: number[] = []; //Slots picked so far for a multi-target card.
   let handHeight = window.innerHeight *0.325;
   let cardSelectRaise = window.innerHeight * 0.032;
 
@@ -145,13 +154,13 @@ import eventBus from "./client";
     board.slots = []; // Reset array
 
     
-    for (let i = 0; i < 3; i++) {
-      for (let z = 0; z < 3; z++) {
+    for (let i = 0; i < boardHeight; i++) {
+      for (let z = 0; z < boardWidth; z++) {
 
-        const id = i * 3 + z;
+        const id = i * boardWidth + z;
         const colour = slotCounter % 2 === 0 ? 0xd3d3d3 : 0xe8e8e8;
-        let x =  ((board.x - 1.5 * slotSize) + slotSize * z);
-        let y =  ((board.y - 1.5 * slotSize) + slotSize * i);
+        let x =  ((board.x - (boardWidth / 2) * slotSize) + slotSize * z);
+        let y =  ((board.y - (boardHeight / 2) * slotSize) + slotSize * i);
         const row = i;
         const col = z;
         const markerGraphic = slotMarkers[id];
@@ -190,7 +199,8 @@ import eventBus from "./client";
           col,
           colour,
           slotGraphic,
-          markerGraphic
+          markerGraphic,
+          effects: []
         };
 
         board.slots.push(slot);
@@ -272,8 +282,11 @@ import eventBus from "./client";
     markSprite.scale.set(0.3);
 
     //Adding card data.
+    let id = data.InstanceID;
     let name = data.Name;
     let description = data.Description;
+    let targeting = data.Targeting;
+    let steps = data.Steps ?? [];
     let selected = false;
     let graphicPath = data.GraphicPath;
     let markerSprite = markSprite;
@@ -286,8 +299,11 @@ import eventBus from "./client";
 
 
     const card:Card = {
+         id,
          name,
          description,
+         targeting,
+         steps,
          selected,
          graphicPath,
          markerSprite,
@@ -397,6 +413,8 @@ import eventBus from "./client";
 
     selectedCard = card;
 
+    HighlightTargets(card);
+
     crdText.text = card.description; //displays text of card.
     crdText.style = style;
     crdText.scale.set(1);
@@ -481,6 +499,8 @@ import eventBus from "./client";
     card.selected = false;
     selectedCard = undefined;
 
+    HighlightTargets(undefined);
+
     for (const card of cardHand) {
       console.log(card);
      }
@@ -493,6 +513,33 @@ import eventBus from "./client";
     }
 
     selectedCard = undefined;
+
+    HighlightTargets(undefined);
+  }
+
+  function IsValidTarget(card:Card, slot:Slot) { //Mirrors the server's targeting rules for the next target to pick.
+    const rule = card.steps.length > 0 ? card.steps[pendingTargets.length].Targeting : card.targeting;
+    if (pendingTargets.includes(slot.id)) {
+      return false;
+    }
+    switch (rule) {
+      case "empty_slot":
+        return !slot.effects.some((e) => e.IsDisplayable);
+      case "enemy_mark":
+        return slot.effects.some((e) => e.IsWinEffect && e.Owner !== playerID);
+      case "own_mark":
+        return slot.effects.some((e) => e.IsWinEffect && e.Owner === playerID);
+    }
+    return true; //any and none.
+  }
+
+  function HighlightTargets(card:Card|undefined) { //Dims slots the card cannot be played on, undefined clears it.
+    if (card === undefined) {
+      pendingTargets = [];
+    }
+    for (const slot of board.slots) {
+      slot.slotGraphic.alpha = (card === undefined || IsValidTarget(card, slot)) ? 1 : 0.4;
+    }
   }
 
   //The play card logic.
@@ -502,7 +549,20 @@ import eventBus from "./client";
       return; //If no selected card, return.
     }
 
-    send({ action: "play_card",type: "play_card", card_name:selectedCard.name,description: selectedCard.description,graphicPath:selectedCard.graphicPath,target_slot:slot.id}); //sending played card to server.
+    if (selectedCard.steps.length > 1) { //Multi-target cards are sent once every target is picked.
+      pendingTargets.push(slot.id);
+      if (pendingTargets.length < selectedCard.steps.length) {
+        HighlightTargets(selectedCard);
+        return;
+      }
+    } else {
+      pendingTargets = [slot.id];
+    }
+
+    send({ action: "play_card",type: "play_card", card_name:selectedCard.name,card_id:selectedCard.id,description: selectedCard.description,graphicPath:selectedCard.graphicPath,target_slot:slot.id,targets:pendingTargets}); //sending played card to server.
+
+    pendingTargets = [];
+    HighlightTargets(selectedCard);
 
 
   }
@@ -615,12 +675,57 @@ import eventBus from "./client";
 
    // console.log(data.cards_to_add);
 
+    if (data.player_id !== undefined) {
+      playerID = data.player_id;
+    }
+
+    if (data.board !== undefined) { //Resizing board to the server's dimensions.
+      boardWidth = data.board.width;
+      boardHeight = data.board.height;
+      CentreBoard();
+      SetSlotListeners();
+    }
 
-    for (let i=0;i<data.cards_to_add.length;i++) { //Drawing starting cards.
+
+    const startCards = data.cards_to_add ?? []; //Empty when resuming with no cards in hand.
+    for (let i=0;i<startCards.length;i++) { //Drawing starting cards.
       //console.log(data.cards_to_add[i].GraphicPath);
-      DrawCard(data.cards_to_add[i]);
+      DrawCard(startCards[i]);
+    }
+
+
+  }
+
+  function ResumeGame(data:JSON) { //Redraws the game from the server's snapshot after reconnecting.
+
+    while (cardHand.length > 0) { //Dropping the stale hand, the snapshot has the current one.
+      RemoveCard(cardHand[0]);
     }
 
+    StartGame(data);
+    UpdateBoard(data);
+    GameOver(data);
+
+    console.log("Resumed game, your turn: " + (data.your_turn ?? false));
+
+  }
+
+  function UpdateHand(data:JSON) { //Applies hand deltas sent by the server.
+
+    if (data.cards_to_remove !== undefined) {
+      for (let i=0;i<data.cards_to_remove.length;i++) {
+        let card = cardHand.find((c) => c.id === data.cards_to_remove[i].InstanceID);
+        if (card !== undefined) {
+          RemoveCard(card);
+        }
+      }
+    }
+
+    if (data.cards_to_add !== undefined) {
+      for (let i=0;i<data.cards_to_add.length;i++) {
+        DrawCard(data.cards_to_add[i]);
+      }
+    }
 
   }
 
@@ -640,6 +745,8 @@ import eventBus from "./client";
 
         if (clSlot.id == sSlot.ID) {
 
+          clSlot.effects = sSlot.Effects ?? []; //Kept for target highlighting.
+
           if (sSlot.Effects == null) { //skips slot if effects is null.
             console.log("Skipping slot: Update empty.");
             continue;
@@ -665,6 +772,19 @@ import eventBus from "./client";
 
 
 
+  function GameOver(data:JSON) {
+
+    let result = data.result;
+
+    if (result === undefined) { //If no result, return.
+      return;
+    }
+
+    console.log("Game over: " + result.reason + " winner: " + result.winner_faction + " slots: " + result.winning_slots);
+
+  }
+
+
   // ------------------ EVENT LISTENERS ------------------------
 
 
@@ -698,6 +818,12 @@ import eventBus from "./client";
         break;
       case "ArrowRight":
         
+        break;
+      case "Enter": //Ends the turn, needed when the room's rules do not end it automatically.
+        send({ action: "end_turn" });
+        break;
+      case "p": //Moves to a new private room, the invite link is logged.
+        send({ action: "create_private_room" });
         break;
     }
 
@@ -722,6 +848,7 @@ import eventBus from "./client";
         DrawCard(jsonData);
         break;
       case "turn_start":
+        UpdateHand(jsonData);
         break;
       case "play_card_success":
         PlayCardSuccess(jsonData);
@@ -729,13 +856,84 @@ import eventBus from "./client";
       case "game_state":
         UpdateBoard(jsonData);
         break;
-      
+      case "game_over":
+        GameOver(jsonData);
+        break;
+      case "spectate": //Watching a game, no hand to draw.
+        StartGame(jsonData);
+        UpdateBoard(jsonData);
+        console.log("Spectating, " + (jsonData.faction ?? "no one") + " to play.");
+        break;
+      case "resume":
+        ResumeGame(jsonData);
+        break;
+      case "opponent_disconnected":
+        console.log("Opponent disconnected, seat held until " + new Date(jsonData.reconnect_by).toLocaleTimeString());
+        break;
+      case "queued":
+        console.log("Queued for a ranked game at rating " + Math.round(jsonData.rating));
+        break;
+      case "match_found":
+        console.log("Ranked match found.");
+        break;
+      case "match_cancelled":
+        console.log("Opponent left before the game started, back in the queue.");
+        break;
+      case "rating":
+        console.log("New rating: " + Math.round(jsonData.rating));
+        break;
+      case "room_joined":
+        console.log("Private room " + jsonData.room_code + ", invite link: " + window.location.origin + window.location.pathname + "?room=" + jsonData.room_code);
+        break;
+      case "error":
+        console.log("Err: " + jsonData.error);
+        break;
+      case "opponent_reconnected":
+        console.log("Opponent reconnected.");
+        break;
+
 
     }
   });
 
   
 
+  async function PlayReplay(replayID:string) { //Fetches a finished game's log and plays back its board states.
+
+    const res = await fetch("/replays/" + replayID);
+    if (!res.ok) {
+      console.log("Err: Replay not found.");
+      return;
+    }
+
+    const lines = (await res.text()).split("\n").filter((l) => l.trim() !== "");
+
+    for (const line of lines) {
+      const entry = JSON.parse(line);
+
+      switch (entry.type) {
+        case "room_created": //Sizing the board to the recorded game.
+          boardWidth = entry.data.board.width;
+          boardHeight = entry.data.board.height;
+          CentreBoard();
+          break;
+        case "board_state":
+          UpdateBoard({ board_state: entry.data.slots } as any);
+          await new Promise((r) => setTimeout(r, 1000)); //Wait between moves.
+          break;
+        case "game_over":
+          GameOver({ result: entry.data } as any);
+          break;
+      }
+    }
+
+  }
+
+  const replayID = new URLSearchParams(window.location.search).get("replay");
+  if (replayID !== null) { //Playing back a recorded game instead of joining one.
+    PlayReplay(replayID);
+  }
+
   app.ticker.add((ticker) =>
     {
         for (const card of cardHand) {